@@ -0,0 +1,93 @@
+package blockchain
+
+import . "goblockchain/common"
+
+const (
+	DIRECTION_SENT     = "sent"
+	DIRECTION_RECEIVED = "received"
+	DIRECTION_SELF     = "self"
+)
+
+type AddressTransaction struct {
+	ID            string  `json:"id"`
+	Direction     string  `json:"direction"`
+	Counterparty  string  `json:"counterparty"`
+	Amount        float32 `json:"amount"`
+	Fee           float32 `json:"fee"`
+	Height        int     `json:"height"`
+	Confirmations int     `json:"confirmations"`
+}
+
+// Direction tells how t moves funds for address, and with whom.
+func Direction(t *Transaction, address string) (string, string) {
+	switch {
+	case t.Tx.SenderAddress == address && t.Tx.RecipientAddress == address:
+		return DIRECTION_SELF, address
+	case t.Tx.SenderAddress == address:
+		return DIRECTION_SENT, t.Tx.RecipientAddress
+	default:
+		return DIRECTION_RECEIVED, t.Tx.SenderAddress
+	}
+}
+
+func (bc *Blockchain) indexAddresses(b *Block, height int) {
+	for i, t := range b.transactions {
+		loc := txLocation{height, i}
+		bc.addrIndex[t.Tx.RecipientAddress] = append(bc.addrIndex[t.Tx.RecipientAddress], loc)
+		if t.Tx.SenderAddress != t.Tx.RecipientAddress {
+			bc.addrIndex[t.Tx.SenderAddress] = append(bc.addrIndex[t.Tx.SenderAddress], loc)
+		}
+	}
+}
+
+// unindexAddresses drops the entries of the block at height, which is
+// always the tip, so they are the last entries of every address.
+func (bc *Blockchain) unindexAddresses(b *Block, height int) {
+	for _, t := range b.transactions {
+		for _, addr := range []string{t.Tx.SenderAddress, t.Tx.RecipientAddress} {
+			locs := bc.addrIndex[addr]
+			for len(locs) > 0 && locs[len(locs)-1].height == height {
+				locs = locs[:len(locs)-1]
+			}
+			if len(locs) == 0 {
+				delete(bc.addrIndex, addr)
+			} else {
+				bc.addrIndex[addr] = locs
+			}
+		}
+	}
+}
+
+// AddressTransactionCount returns how many confirmed transactions involve
+// address.
+func (bc *Blockchain) AddressTransactionCount(address string) int {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
+	return len(bc.addrIndex[address])
+}
+
+// AddressTransactions returns up to limit confirmed transactions of
+// address, newest first, skipping the first offset ones.
+func (bc *Blockchain) AddressTransactions(address string, offset int, limit int) []*AddressTransaction {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
+	locs := bc.addrIndex[address]
+	result := make([]*AddressTransaction, 0)
+	for i := len(locs) - 1 - offset; i >= 0 && len(result) < limit; i-- {
+		loc := locs[i]
+		t := bc.chain[loc.height].transactions[loc.index]
+		at := &AddressTransaction{
+			ID:            BlockTransactionID(t, loc.height, loc.index),
+			Amount:        t.Tx.Value,
+			Fee:           t.Tx.Fee,
+			Height:        loc.height,
+			Confirmations: bc.height() - loc.height + 1,
+		}
+		at.Direction, at.Counterparty = Direction(t, address)
+		if at.Direction == DIRECTION_RECEIVED {
+			at.Fee = 0
+		}
+		result = append(result, at)
+	}
+	return result
+}
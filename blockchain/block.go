@@ -13,10 +13,11 @@ type Block struct {
 	timestamp    int64
 	nonce        int
 	previousHash [32]byte
-	transactions []*BlockTransaction
+	transactions []*Transaction
+	height       int
 }
 
-func NewBlock(nonce int, previousHash [32]byte, transactions []*BlockTransaction) *Block { // * is a pointer, & is a reference
+func NewBlock(nonce int, previousHash [32]byte, transactions []*Transaction) *Block { // * is a pointer, & is a reference
 	b := new(Block) // new() return a pointer
 	b.timestamp = time.Now().UnixNano()
 	b.nonce = nonce
@@ -25,6 +26,24 @@ func NewBlock(nonce int, previousHash [32]byte, transactions []*BlockTransaction
 	return b
 }
 
+// NewGenesisBlock builds the fixed first block of a network, so that every
+// node of the same network starts from the same genesis hash.
+func NewGenesisBlock(network *Network) *Block {
+	b := new(Block)
+	b.timestamp = network.GenesisTimestamp
+	b.nonce = network.GenesisNonce
+	b.transactions = []*Transaction{}
+	return b
+}
+
+func (b *Block) Timestamp() int64 {
+	return b.timestamp
+}
+
+func (b *Block) Height() int {
+	return b.height
+}
+
 func (b *Block) PreviousHash() [32]byte {
 	return b.previousHash
 }
@@ -33,7 +52,7 @@ func (b *Block) Nonce() int {
 	return b.nonce
 }
 
-func (b *Block) Transactions() []*BlockTransaction {
+func (b *Block) Transactions() []*Transaction {
 	return b.transactions
 }
 
@@ -42,23 +61,27 @@ func (b *Block) Print() { // create method Print in Block
 	fmt.Printf("nonce               %d\n", b.nonce)
 	fmt.Printf("previousHash        %x\n", b.previousHash)
 	for _, t := range b.transactions {
-		t.Print()
+		t.Tx.Print()
 	}
 }
 
 func (b *Block) Hash() [32]byte {
-	m, _ := json.Marshal(b)
-	sum := sha256.Sum256([]byte(m))
+	m := EncodeBlockHeader(b.timestamp, b.nonce, b.previousHash, TransactionsHash(b.transactions))
+	sum := sha256.Sum256(m)
 	return sum
 }
 
 func (b *Block) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		Timestamp    int64               `json:"timestamp"`
-		Nonce        int                 `json:"nonce"`
-		PreviousHash string              `json:"previous_hash"`
-		Transactions []*BlockTransaction `json:"transactions"`
+		Hash         string         `json:"hash"`
+		Height       int            `json:"height"`
+		Timestamp    int64          `json:"timestamp"`
+		Nonce        int            `json:"nonce"`
+		PreviousHash string         `json:"previous_hash"`
+		Transactions []*Transaction `json:"transactions"`
 	}{
+		Hash:         fmt.Sprintf("%x", b.Hash()),
+		Height:       b.height,
 		Timestamp:    b.timestamp,
 		Nonce:        b.nonce,
 		PreviousHash: fmt.Sprintf("%x", b.previousHash),
@@ -69,11 +92,13 @@ func (b *Block) MarshalJSON() ([]byte, error) {
 func (b *Block) UnmarshalJSON(data []byte) error {
 	var previousHash string
 	v := &struct {
-		Timestamp        *int64               `json:"timestamp"`
-		Nonce            *int                 `json:"nonce"`
-		PreviousHash     *string              `json:"previous_hash"`
-		BlockTransaction *[]*BlockTransaction `json:"transactions"`
+		Height           *int            `json:"height"`
+		Timestamp        *int64          `json:"timestamp"`
+		Nonce            *int            `json:"nonce"`
+		PreviousHash     *string         `json:"previous_hash"`
+		BlockTransaction *[]*Transaction `json:"transactions"`
 	}{
+		Height:           &b.height,
 		Timestamp:        &b.timestamp,
 		Nonce:            &b.nonce,
 		PreviousHash:     &previousHash,
@@ -83,6 +108,6 @@ func (b *Block) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	ph, _ := hex.DecodeString(*v.PreviousHash)
-	copy(b.previousHash[:], ph[:32])
+	copy(b.previousHash[:], ph)
 	return nil
 }
@@ -12,40 +12,108 @@ import (
 )
 
 type Blockchain struct {
-	transactionPool   []*BlockTransaction
+	transactionPool   []*Transaction
 	chain             []*Block
 	blockchainAddress string
 	port              uint16
+	network           *Network
+	txIndex           map[string]txLocation
+	blockIndex        map[[32]byte]int
+	addrIndex         map[string][]txLocation
+	ledger            *ledger      // state of the chain
+	poolLedger        *ledger      // state of the chain and the pool
+	muxChain          sync.RWMutex // guards the chain, the pool, the ledgers and the indexes
+	events            eventBus
 	muxMining         sync.Mutex
 
 	neighbors    []string
 	muxNeighbors sync.Mutex
 }
 
-func NewBlockchain(blockchainAddress string, port uint16) *Blockchain {
-	b := &Block{}
+func NewBlockchain(blockchainAddress string, port uint16, network *Network) *Blockchain {
 	bc := new(Blockchain)
 	bc.blockchainAddress = blockchainAddress
 	bc.port = port
-	bc.CreateBlock(0, b.Hash())
+	bc.network = network
+	bc.txIndex = make(map[string]txLocation)
+	bc.blockIndex = make(map[[32]byte]int)
+	bc.addrIndex = make(map[string][]txLocation)
+	bc.ledger = newLedger(nil)
+	bc.poolLedger = newLedger(bc.ledger)
+	bc.connectBlock(NewGenesisBlock(network))
 	return bc
 }
 
+func (bc *Blockchain) Network() *Network {
+	return bc.network
+}
+
 func (bc *Blockchain) Chain() []*Block {
-	return bc.chain
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
+	return append([]*Block{}, bc.chain...)
 }
 
-func (bc *Blockchain) CreateBlock(nonce int, previousHash [32]byte) *Block {
-	b := NewBlock(nonce, previousHash, bc.transactionPool)
-	bc.chain = append(bc.chain, b)
-	bc.transactionPool = []*BlockTransaction{}
+func (bc *Blockchain) CreateBlock(nonce int, previousHash [32]byte, transactions []*Transaction) *Block {
+	b := NewBlock(nonce, previousHash, transactions)
+	bc.muxChain.Lock()
+	bc.connectBlock(b)
+	bc.removeFromTransactionPool(transactions)
+	bc.muxChain.Unlock()
 
-	bc.NodeSyncNewBlock()
+	bc.NodeSyncConsensus()
 
 	return b
 }
 
+// connectBlock appends b to the chain and adds it to the ledger and the
+// indexes. It and the other unexported methods changing the chain or the
+// pool are called with muxChain held. Whoever connects blocks updates the
+// pool after, its ledger counting them twice until then.
+func (bc *Blockchain) connectBlock(b *Block) {
+	height := len(bc.chain)
+	bc.chain = append(bc.chain, b)
+	for _, t := range b.transactions {
+		bc.ledger.apply(t)
+	}
+	bc.indexBlock(b, height)
+	bc.indexAddresses(b, height)
+	bc.events.publish(&Event{Type: EVENT_BLOCK_CONNECTED, Block: b})
+}
+
+// disconnectBlock removes the last block from the chain, the ledger and the
+// indexes.
+func (bc *Blockchain) disconnectBlock() *Block {
+	height := bc.height()
+	b := bc.chain[height]
+	for i := len(b.transactions) - 1; i >= 0; i-- {
+		bc.ledger.revert(b.transactions[i])
+	}
+	bc.unindexAddresses(b, height)
+	bc.unindexBlock(b, height)
+	bc.chain = bc.chain[:height]
+	bc.events.publish(&Event{Type: EVENT_BLOCK_DISCONNECTED, Block: b})
+	return b
+}
+
+// replaceChain switches to chain, disconnecting our blocks past the fork
+// point and connecting the new ones in their place.
+func (bc *Blockchain) replaceChain(chain []*Block) {
+	fork := 0
+	for fork < len(bc.chain) && fork < len(chain) && bc.chain[fork].Hash() == chain[fork].Hash() {
+		fork += 1
+	}
+	for bc.height() >= fork {
+		bc.disconnectBlock()
+	}
+	for _, b := range chain[fork:] {
+		bc.connectBlock(b)
+	}
+}
+
 func (bc *Blockchain) Print() {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
 	for i, block := range bc.chain {
 		fmt.Printf("%s Block %d %s\n", strings.Repeat("=", 25), i, strings.Repeat("=", 25))
 		block.Print()
@@ -54,6 +122,8 @@ func (bc *Blockchain) Print() {
 }
 
 func (bc *Blockchain) MarshalJSON() ([]byte, error) {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
 	return json.Marshal(struct {
 		Blocks []*Block `json:"blockchain"`
 	}{
@@ -62,6 +132,8 @@ func (bc *Blockchain) MarshalJSON() ([]byte, error) {
 }
 
 func (bc *Blockchain) UnmarshalJSON(data []byte) error {
+	bc.muxChain.Lock()
+	defer bc.muxChain.Unlock()
 	v := &struct {
 		Blocks *[]*Block `json:"blockchain"`
 	}{
@@ -73,72 +145,148 @@ func (bc *Blockchain) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+func (bc *Blockchain) BlockByHeight(height int) *Block {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
+	if height < 0 || height >= len(bc.chain) {
+		return nil
+	}
+	return bc.chain[height]
+}
+
+func (bc *Blockchain) BlockByHash(hash [32]byte) *Block {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
+	if height, ok := bc.blockIndex[hash]; ok {
+		return bc.chain[height]
+	}
+	return nil
+}
+
+// Blocks returns up to limit blocks starting at height from.
+func (bc *Blockchain) Blocks(from int, limit int) []*Block {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
+	if from < 0 || from >= len(bc.chain) || limit <= 0 {
+		return []*Block{}
+	}
+	to := from + limit
+	if to > len(bc.chain) {
+		to = len(bc.chain)
+	}
+	return append([]*Block{}, bc.chain[from:to]...)
+}
+
 func (bc *Blockchain) LastBlock() *Block {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
 	return bc.chain[len(bc.chain)-1]
 }
 
 func (bc *Blockchain) LastHash() [32]byte {
-	return bc.chain[len(bc.chain)-1].Hash()
+	return bc.LastBlock().Hash()
 }
 
 func (bc *Blockchain) CreateTransaction(t *Transaction) bool {
-	isTransacted := bc.AddTransaction(t)
-	if isTransacted {
-		bc.NodeSyncTransaction(t)
+	return bc.SubmitTransaction(t) == nil
+}
+
+// SubmitTransaction adds t to the pool and relays it to the neighbors,
+// returning why it was rejected otherwise.
+func (bc *Blockchain) SubmitTransaction(t *Transaction) error {
+	if err := bc.addTransaction(t); err != nil {
+		return err
 	}
-	return isTransacted
+	bc.NodeSyncTransaction(t)
+	return nil
 }
 
 func (bc *Blockchain) AddTransaction(t *Transaction) bool {
+	return bc.addTransaction(t) == nil
+}
 
-	if t.Tx.SenderAddress == MINING_SENDER {
-		bc.transactionPool = append(bc.transactionPool, &t.Tx)
-		return true
+func (bc *Blockchain) addTransaction(t *Transaction) error {
+	bc.muxChain.Lock()
+	defer bc.muxChain.Unlock()
+	if err := bc.checkTransaction(t); err != nil {
+		log.Printf("ERROR: %v", err)
+		return err
 	}
 
-	if !VerifyTransaction(t.SenderPublicKey, t.Signature, &t.Tx) {
-		log.Println("ERROR: Verifiy Transaction")
-		return false
-	}
+	bc.transactionPool = append(bc.transactionPool, t)
+	bc.poolLedger.apply(t)
+	bc.events.publish(&Event{Type: EVENT_TX_ADDED, Transaction: t})
+	return nil
+}
 
-	if bc.CalculateTotalAmount(t.Tx.SenderAddress) < t.Tx.Value {
-		log.Println("ERROR: Not Enough Gas")
-		return false
-	}
+// CheckTransaction validates t against the confirmed chain and the
+// transactions already waiting in the pool.
+func (bc *Blockchain) CheckTransaction(t *Transaction) error {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
+	return bc.checkTransaction(t)
+}
 
-	bc.transactionPool = append(bc.transactionPool, &t.Tx)
-	return true
+func (bc *Blockchain) checkTransaction(t *Transaction) error {
+	return bc.poolLedger.check(bc.network, t)
 }
 
-func (bc *Blockchain) CopyTransactionPool() []*BlockTransaction {
-	transactions := make([]*BlockTransaction, 0)
+// NextNonce returns the nonce of the next transaction of address, counting
+// the ones waiting in the pool.
+func (bc *Blockchain) NextNonce(address string) uint64 {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
+	return bc.poolLedger.nonce(address)
+}
+
+func (bc *Blockchain) CopyTransactionPool() []*Transaction {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
+	transactions := make([]*Transaction, 0)
 	for _, t := range bc.transactionPool {
-		transactions = append(transactions,
-			NewTransaction(t.SenderAddress, t.RecipientAddress, t.Value))
+		c := *t
+		transactions = append(transactions, &c)
 	}
 	return transactions
 }
 
-func (bc *Blockchain) TransactionPool() []*BlockTransaction {
-	return bc.transactionPool
+func (bc *Blockchain) TransactionPool() []*Transaction {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
+	return append([]*Transaction{}, bc.transactionPool...)
 }
 
-func (bc *Blockchain) ClearTransactionPool() {
-	bc.transactionPool = bc.transactionPool[:0]
+// removeFromTransactionPool drops the transactions of a block from the
+// pool, keeping the ones added while it was being mined.
+func (bc *Blockchain) removeFromTransactionPool(transactions []*Transaction) {
+	included := make(map[string]bool)
+	for _, t := range transactions {
+		included[TransactionID(t)] = true
+	}
+	pool := bc.transactionPool
+	bc.transactionPool = []*Transaction{}
+	bc.poolLedger = newLedger(bc.ledger)
+	for _, t := range pool {
+		if included[TransactionID(t)] {
+			bc.events.publish(&Event{Type: EVENT_TX_REMOVED, Transaction: t})
+			continue
+		}
+		bc.transactionPool = append(bc.transactionPool, t)
+		bc.poolLedger.apply(t)
+	}
 }
 
-func (bc *Blockchain) ValidProof(nonce int, previousHash [32]byte, transactions []*BlockTransaction, dificulty int) bool {
+func (bc *Blockchain) ValidProof(nonce int, previousHash [32]byte, transactions []*Transaction, dificulty int) bool {
 	zeros := strings.Repeat("0", dificulty)
-	guessBlock := Block{0, nonce, previousHash, transactions}
+	guessBlock := Block{nonce: nonce, previousHash: previousHash, transactions: transactions}
 	guessHash := fmt.Sprintf("%x", guessBlock.Hash())
 	return guessHash[:dificulty] == zeros
 }
 
-func (bc *Blockchain) ProofOfWork() int {
-	transactions := bc.CopyTransactionPool()
+func (bc *Blockchain) ProofOfWork(transactions []*Transaction) int {
 	previousHash := bc.LastHash()
 	nonce := 0
-	for !bc.ValidProof(nonce, previousHash, transactions, MINING_DIFFICULTY) {
+	for !bc.ValidProof(nonce, previousHash, transactions, bc.network.Difficulty) {
 		nonce += 1
 	}
 	return nonce
@@ -148,20 +296,19 @@ func (bc *Blockchain) Mining() bool {
 	bc.muxMining.Lock()
 	defer bc.muxMining.Unlock()
 
-	t := NewTransaction(MINING_SENDER, bc.blockchainAddress, MINING_REWARD)
-	tt := Transaction{Tx: *t}
-	created := bc.AddTransaction(&tt)
-	if !created {
-		return false
+	transactions := bc.CopyTransactionPool()
+	coinbase := NewCoinbase(bc.blockchainAddress, bc.BlockReward(), transactions)
+	if coinbase.Tx.Value > 0 {
+		transactions = append([]*Transaction{coinbase}, transactions...)
 	}
 
-	nonce := bc.ProofOfWork()
+	nonce := bc.ProofOfWork(transactions)
 	conflict := bc.ResolveConflicts()
 	if conflict {
 		return false
 	}
 
-	bc.CreateBlock(nonce, bc.LastHash())
+	bc.CreateBlock(nonce, bc.LastHash(), transactions)
 	log.Println("action=mining, status=success")
 
 	return true
@@ -181,15 +328,17 @@ func (bc *Blockchain) StartMining() {
 }
 
 func (bc *Blockchain) CalculateTotalAmount(blockchainAddress string) float32 {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
 	var totalAmount float32 = 0.0
 	for _, b := range bc.chain {
 		for _, t := range b.transactions {
-			value := t.Value
-			if blockchainAddress == t.RecipientAddress {
+			value := t.Tx.Value
+			if blockchainAddress == t.Tx.RecipientAddress {
 				totalAmount += value
 			}
-			if blockchainAddress == t.SenderAddress {
-				totalAmount -= value
+			if blockchainAddress == t.Tx.SenderAddress {
+				totalAmount -= value + t.Tx.Fee
 			}
 		}
 	}
@@ -197,27 +346,73 @@ func (bc *Blockchain) CalculateTotalAmount(blockchainAddress string) float32 {
 }
 
 func (bc *Blockchain) ValidChain(chain []*Block) bool {
-	preBlock := chain[0]
-	currentIndex := 1
-	for currentIndex < len(chain) {
-		b := chain[currentIndex]
-		if b.previousHash != preBlock.Hash() {
-			return false
+	if err := bc.VerifyChain(chain); err != nil {
+		log.Printf("ERROR: Invalid Chain: %v", err)
+		return false
+	}
+	return true
+}
+
+// VerifyChain replays chain from its genesis block, checking links, proofs
+// of work, coinbases, signatures and balances. It returns a
+// *ValidationError describing the first invalid block and transaction.
+func (bc *Blockchain) VerifyChain(chain []*Block) error {
+	if len(chain) == 0 || chain[0].Hash() != NewGenesisBlock(bc.network).Hash() {
+		return &ValidationError{Height: 0, TxIndex: -1, Err: ErrInvalidGenesis}
+	}
+	l := newLedger(nil)
+	var issued float32 = 0.0
+	for height := 1; height < len(chain); height++ {
+		b := chain[height]
+		if b.previousHash != chain[height-1].Hash() {
+			return &ValidationError{Height: height, TxIndex: -1, Err: ErrInvalidPreviousHash}
+		}
+		if !bc.ValidProof(b.Nonce(), b.PreviousHash(), b.Transactions(), bc.network.Difficulty) {
+			return &ValidationError{Height: height, TxIndex: -1, Err: ErrInvalidProof}
+		}
+		reward := bc.network.Emission.Reward(height, issued)
+		for i, t := range b.transactions {
+			var err error
+			if i == 0 && t.Tx.SenderAddress == MINING_SENDER {
+				err = validCoinbase(bc.network, t, reward, b.transactions)
+			} else {
+				err = l.check(bc.network, t)
+			}
+			if err != nil {
+				return &ValidationError{Height: height, TxIndex: i, Err: err}
+			}
+			l.apply(t)
 		}
-		if !bc.ValidProof(b.Nonce(), b.PreviousHash(), b.Transactions(), MINING_DIFFICULTY) {
-			return false
+		issued += subsidy(b)
+	}
+	return nil
+}
+
+// revalidateTransactionPool drops pooled transactions that are no longer
+// valid on top of the current chain, e.g. after it was replaced.
+func (bc *Blockchain) revalidateTransactionPool() {
+	pool := bc.transactionPool
+	bc.transactionPool = []*Transaction{}
+	bc.poolLedger = newLedger(bc.ledger)
+	for _, t := range pool {
+		if err := bc.checkTransaction(t); err != nil {
+			log.Printf("ERROR: dropping pooled transaction: %v", err)
+			bc.events.publish(&Event{Type: EVENT_TX_REMOVED, Transaction: t})
+			continue
 		}
-		preBlock = b
-		currentIndex += 1
+		bc.transactionPool = append(bc.transactionPool, t)
+		bc.poolLedger.apply(t)
 	}
-	return true
 }
 
+// ResolveConflicts switches to the longest valid chain of the neighbors.
+// The chains are fetched and verified without holding muxChain, so it is
+// checked again that ours is still shorter before replacing it.
 func (bc *Blockchain) ResolveConflicts() bool {
 	var longestChain []*Block = nil
-	maxLength := len(bc.chain)
+	maxLength := bc.Height() + 1
 
-	for _, n := range bc.neighbors {
+	for _, n := range bc.Neighbors() {
 		chain := bc.NodeSyncChain(n)
 		if len(chain) > maxLength && bc.ValidChain(chain) {
 			maxLength = len(chain)
@@ -226,9 +421,14 @@ func (bc *Blockchain) ResolveConflicts() bool {
 	}
 
 	if longestChain != nil {
-		bc.chain = longestChain
-		log.Printf("Resolve conflicts replaced")
-		return true
+		bc.muxChain.Lock()
+		defer bc.muxChain.Unlock()
+		if len(longestChain) > len(bc.chain) {
+			bc.replaceChain(longestChain)
+			bc.revalidateTransactionPool()
+			log.Printf("Resolve conflicts replaced")
+			return true
+		}
 	}
 	log.Printf("Resolve conflicts not replaced")
 	return false
@@ -0,0 +1,94 @@
+package blockchain
+
+import (
+	"encoding/json"
+	. "goblockchain/common"
+	"goblockchain/wallet"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveChain serves the chain of bc to neighbors, as /blockchain does.
+func serveChain(bc *Blockchain) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		m, _ := json.Marshal(bc)
+		res.Write(m)
+	}))
+}
+
+func TestResolveConflictsKeepsUnminedTransactions(t *testing.T) {
+	sender := wallet.NewWallet(REGTEST)
+	recipient := wallet.NewWallet(REGTEST).BlockchainAddress()
+	miner := newTestChain(t, sender)
+	node := NewBlockchain(wallet.NewWallet(REGTEST).BlockchainAddress(), 0, REGTEST)
+	server := serveChain(miner)
+	defer server.Close()
+	node.neighbors = []string{strings.TrimPrefix(server.URL, "http://")}
+	if !node.ResolveConflicts() {
+		t.Fatal("the node did not take the chain of the miner")
+	}
+
+	mined := signedTransaction(t, sender, recipient, 1, 0)
+	unmined := signedTransaction(t, sender, recipient, 2, 1)
+	for _, tx := range []*Transaction{mined, unmined} {
+		if err := node.SubmitTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := miner.SubmitTransaction(mined); err != nil {
+		t.Fatal(err)
+	}
+	if !miner.Mining() {
+		t.Fatal("mining failed")
+	}
+
+	if !node.ResolveConflicts() {
+		t.Fatal("the node did not take the new block")
+	}
+	pool := node.TransactionPool()
+	if len(pool) != 1 || TransactionID(pool[0]) != TransactionID(unmined) {
+		t.Errorf("pool after the new block = %v, want the unmined transaction only", pool)
+	}
+}
+
+func TestLedgerFollowsReorg(t *testing.T) {
+	sender := wallet.NewWallet(REGTEST)
+	recipient := wallet.NewWallet(REGTEST).BlockchainAddress()
+	miner := newTestChain(t, sender)
+	node := NewBlockchain(wallet.NewWallet(REGTEST).BlockchainAddress(), 0, REGTEST)
+	server := serveChain(miner)
+	defer server.Close()
+	node.neighbors = []string{strings.TrimPrefix(server.URL, "http://")}
+	node.ResolveConflicts()
+
+	// the node mines a block of its own, the miner two others
+	if err := node.SubmitTransaction(signedTransaction(t, sender, recipient, 3, 0)); err != nil {
+		t.Fatal(err)
+	}
+	node.CreateBlock(0, node.LastHash(), node.TransactionPool())
+	if err := miner.SubmitTransaction(signedTransaction(t, sender, recipient, 1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	miner.Mining()
+	miner.Mining()
+	if !node.ResolveConflicts() {
+		t.Fatal("the node did not switch to the longer chain")
+	}
+
+	replayed := newLedger(nil)
+	for _, b := range node.Chain() {
+		for _, tx := range b.transactions {
+			replayed.apply(tx)
+		}
+	}
+	for _, address := range []string{sender.BlockchainAddress(), recipient, node.blockchainAddress} {
+		if got, want := node.ledger.balance(address), replayed.balance(address); got != want {
+			t.Errorf("balance of %s = %f, want %f", address, got, want)
+		}
+		if got, want := node.ledger.nonce(address), replayed.nonce(address); got != want {
+			t.Errorf("nonce of %s = %d, want %d", address, got, want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package blockchain
+
+import (
+	"fmt"
+	. "goblockchain/common"
+)
+
+// NewCoinbase builds the transaction through which a miner claims the block
+// reward plus the fees of the transactions it includes.
+func NewCoinbase(recipient string, reward float32, transactions []*Transaction) *Transaction {
+	t := NewTransaction(MINING_SENDER, recipient, reward+totalFees(transactions))
+	return &Transaction{Tx: *t}
+}
+
+func totalFees(transactions []*Transaction) float32 {
+	var fees float32 = 0.0
+	for _, t := range transactions {
+		if t.Tx.SenderAddress != MINING_SENDER {
+			fees += t.Tx.Fee
+		}
+	}
+	return fees
+}
+
+// subsidy returns the newly issued coins of a block, i.e. what its coinbase
+// pays on top of the fees collected from the other transactions.
+func subsidy(b *Block) float32 {
+	if len(b.transactions) == 0 || b.transactions[0].Tx.SenderAddress != MINING_SENDER {
+		return 0
+	}
+	s := b.transactions[0].Tx.Value - totalFees(b.transactions)
+	if s < 0 {
+		return 0
+	}
+	return s
+}
+
+// validCoinbase checks that the first transaction of a block pays an
+// address of network something, at most reward plus the fees of the block.
+// Mint transactions anywhere else are rejected by the ledger.
+func validCoinbase(network *Network, coinbase *Transaction, reward float32, transactions []*Transaction) error {
+	if coinbase.Tx.Fee != 0 {
+		return fmt.Errorf("%w: coinbase carries a fee", ErrInvalidCoinbase)
+	}
+	if !(coinbase.Tx.Value > 0) {
+		return fmt.Errorf("%w: pays %f", ErrInvalidCoinbase, coinbase.Tx.Value)
+	}
+	if err := network.ValidateAddress(coinbase.Tx.RecipientAddress); err != nil {
+		return fmt.Errorf("%w: recipient: %v", ErrInvalidCoinbase, err)
+	}
+	if limit := reward + totalFees(transactions); coinbase.Tx.Value > limit {
+		return fmt.Errorf("%w: pays %f, more than %f", ErrInvalidCoinbase, coinbase.Tx.Value, limit)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package blockchain
+
+import (
+	"errors"
+	. "goblockchain/common"
+	"goblockchain/wallet"
+	"testing"
+)
+
+func TestValidCoinbase(t *testing.T) {
+	miner := wallet.NewWallet(REGTEST).BlockchainAddress()
+	corrupted := []byte(miner)
+	corrupted[len(corrupted)-1] ^= 1
+	paid := &Transaction{Tx: BlockTransaction{SenderAddress: wallet.NewWallet(REGTEST).BlockchainAddress(), Fee: 0.5}}
+	coinbase := func(recipient string, value float32, fee float32) *Transaction {
+		return &Transaction{Tx: BlockTransaction{SenderAddress: MINING_SENDER, RecipientAddress: recipient, Value: value, Fee: fee}}
+	}
+	tests := []struct {
+		name     string
+		coinbase *Transaction
+		ok       bool
+	}{
+		{"reward and fees", coinbase(miner, 50.5, 0), true},
+		{"less than the reward", coinbase(miner, 1, 0), true},
+		{"more than the reward and fees", coinbase(miner, 50.75, 0), false},
+		{"zero", coinbase(miner, 0, 0), false},
+		{"negative", coinbase(miner, -1, 0), false},
+		{"fee", coinbase(miner, 50, 0.5), false},
+		{"no recipient", coinbase("", 50, 0), false},
+		{"recipient of another network", coinbase(wallet.NewWallet(MAINNET).BlockchainAddress(), 50, 0), false},
+		{"bad checksum", coinbase(string(corrupted), 50, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validCoinbase(REGTEST, tt.coinbase, 50, []*Transaction{tt.coinbase, paid})
+			if tt.ok != (err == nil) {
+				t.Fatalf("validCoinbase() = %v", err)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidCoinbase) {
+				t.Errorf("validCoinbase() = %v, want %v", err, ErrInvalidCoinbase)
+			}
+		})
+	}
+}
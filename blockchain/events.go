@@ -0,0 +1,88 @@
+package blockchain
+
+import (
+	. "goblockchain/common"
+	"sync"
+)
+
+const (
+	EVENT_BLOCK_CONNECTED    = "block_connected"
+	EVENT_BLOCK_DISCONNECTED = "block_disconnected"
+	EVENT_TX_ADDED           = "tx_added"
+	EVENT_TX_REMOVED         = "tx_removed"
+
+	EVENT_BUFFER_SIZE = 64
+)
+
+type Event struct {
+	Type        string
+	Block       *Block
+	Transaction *Transaction
+}
+
+// Involves reports whether the event touches address, as sender or
+// recipient of its transaction or of any transaction of its block.
+func (e *Event) Involves(address string) bool {
+	if e.Transaction != nil {
+		return e.Transaction.Tx.SenderAddress == address || e.Transaction.Tx.RecipientAddress == address
+	}
+	if e.Block != nil {
+		for _, t := range e.Block.transactions {
+			if t.Tx.SenderAddress == address || t.Tx.RecipientAddress == address {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// eventBus is the internal bus on which the chain announces blocks being
+// connected or disconnected and transactions entering or leaving the pool.
+// It fans events out to subscribers. A subscriber whose buffer is
+// full is dropped and its channel closed rather than stalling the chain, so
+// it can tell it missed events and resubscribe.
+type eventBus struct {
+	subscribers map[chan *Event]bool
+	mux         sync.Mutex
+}
+
+func (eb *eventBus) subscribe() chan *Event {
+	eb.mux.Lock()
+	defer eb.mux.Unlock()
+	if eb.subscribers == nil {
+		eb.subscribers = make(map[chan *Event]bool)
+	}
+	ch := make(chan *Event, EVENT_BUFFER_SIZE)
+	eb.subscribers[ch] = true
+	return ch
+}
+
+func (eb *eventBus) unsubscribe(ch chan *Event) {
+	eb.mux.Lock()
+	defer eb.mux.Unlock()
+	if eb.subscribers[ch] {
+		delete(eb.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (eb *eventBus) publish(e *Event) {
+	eb.mux.Lock()
+	defer eb.mux.Unlock()
+	for ch := range eb.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(eb.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns a channel receiving every event of the chain, and a
+// function to stop receiving them. The channel is closed on unsubscribe or
+// when the subscriber falls too far behind.
+func (bc *Blockchain) Subscribe() (<-chan *Event, func()) {
+	ch := bc.events.subscribe()
+	return ch, func() { bc.events.unsubscribe(ch) }
+}
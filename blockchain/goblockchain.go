@@ -12,30 +12,16 @@ import (
 )
 
 const (
-	MINING_DIFFICULTY = 3
-	MINING_SENDER     = "THE BLOCKCHAIN"
-	MINING_REWARD     = 1.0
-	MINING_TIMER_MIN  = 2
+	MINING_SENDER    = "THE BLOCKCHAIN"
+	MINING_TIMER_MIN = 2
 
-	BLOCKCHAIN_PORT_RANGE_START      = 5000
-	BLOCKCHAIN_PORT_RANGE_END        = 5003
 	NEIGHBOR_IP_RANGE_START          = 0
 	NEIGHBOR_IP_RANGE_END            = 1
 	BLOCKCHIN_NEIGHBOR_SYNC_TIME_SEC = 30
 )
 
-func (bc *Blockchain) NodeSyncNewBlock() {
-	for _, n := range bc.neighbors {
-		endpoint := fmt.Sprintf("http://%s/transactions", n)
-		client := &http.Client{}
-		req, _ := http.NewRequest("DELETE", endpoint, nil)
-		client.Do(req)
-		//log.Printf("%v", resp)
-	}
-}
-
 func (bc *Blockchain) NodeSyncTransaction(t *common.Transaction) {
-	for _, n := range bc.neighbors {
+	for _, n := range bc.Neighbors() {
 		m, _ := json.Marshal(&t)
 		buf := bytes.NewBuffer(m)
 		endpoint := fmt.Sprintf("http://%s/transactions", n)
@@ -48,7 +34,11 @@ func (bc *Blockchain) NodeSyncTransaction(t *common.Transaction) {
 
 func (bc *Blockchain) NodeSyncChain(n string) []*Block {
 	endpoint := fmt.Sprintf("http://%s/blockchain", n)
-	resp, _ := http.Get(endpoint)
+	resp, err := http.Get(endpoint)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
 	if resp.StatusCode == 200 {
 		var bcResp Blockchain
 		decoder := json.NewDecoder(resp.Body)
@@ -59,9 +49,12 @@ func (bc *Blockchain) NodeSyncChain(n string) []*Block {
 	return nil
 }
 
+// NodeSyncConsensus asks the neighbors to resolve conflicts, so that they
+// take a block mined here and drop its transactions from their pools,
+// keeping the ones it does not include.
 func (bc *Blockchain) NodeSyncConsensus() {
 	client := &http.Client{}
-	for _, n := range bc.neighbors {
+	for _, n := range bc.Neighbors() {
 		endpoint := fmt.Sprintf("http://%s/consensus", n)
 		req, _ := http.NewRequest("PUT", endpoint, nil)
 		client.Do(req)
@@ -69,17 +62,26 @@ func (bc *Blockchain) NodeSyncConsensus() {
 	}
 }
 
+func (bc *Blockchain) Neighbors() []string {
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	return append([]string{}, bc.neighbors...)
+}
+
+// SetNeighbors looks for the neighbors, without holding muxNeighbors
+// while it probes them.
 func (bc *Blockchain) SetNeighbors() {
-	bc.neighbors = nodes.FindNeighbors(
+	neighbors := nodes.FindNeighbors(
 		nodes.GetHost(), bc.port,
 		NEIGHBOR_IP_RANGE_START, NEIGHBOR_IP_RANGE_END,
-		BLOCKCHAIN_PORT_RANGE_START, BLOCKCHAIN_PORT_RANGE_END)
-	//log.Printf("%v", bc.neighbors)
+		bc.network.PortRangeStart, bc.network.PortRangeEnd)
+	//log.Printf("%v", neighbors)
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	bc.neighbors = neighbors
 }
 
 func (bc *Blockchain) SyncNeighbors() {
-	bc.muxNeighbors.Lock()
-	defer bc.muxNeighbors.Unlock()
 	bc.SetNeighbors()
 }
 
@@ -95,7 +97,7 @@ func (bc *Blockchain) Run() {
 	tt := time.Now()
 	m := tt.Minute() % MINING_TIMER_MIN
 	t := tt.Truncate(time.Minute).
-		Add(time.Minute * time.Duration(MINING_TIMER_MIN + m))
+		Add(time.Minute * time.Duration(MINING_TIMER_MIN+m))
 	log.Printf("Mining will start at %s", t.Format("15:04:05"))
 	time.Sleep(time.Until(t))
 	bc.StartMining()
@@ -0,0 +1,69 @@
+package blockchain
+
+type Supply struct {
+	Height            int     `json:"height"`
+	BlockReward       float32 `json:"block_reward"`
+	IssuedRewards     float32 `json:"issued_rewards"`
+	CirculatingSupply float32 `json:"circulating_supply"`
+	MaxSupply         float32 `json:"max_supply"`
+	NextHalvingHeight int     `json:"next_halving_height"`
+}
+
+func issuedRewards(chain []*Block) float32 {
+	var issued float32 = 0.0
+	for _, b := range chain {
+		issued += subsidy(b)
+	}
+	return issued
+}
+
+func (bc *Blockchain) Height() int {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
+	return bc.height()
+}
+
+func (bc *Blockchain) height() int {
+	return len(bc.chain) - 1
+}
+
+// BlockReward returns the reward the next mined block may claim.
+func (bc *Blockchain) BlockReward() float32 {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
+	return bc.blockReward()
+}
+
+func (bc *Blockchain) blockReward() float32 {
+	return bc.network.Emission.Reward(bc.height()+1, issuedRewards(bc.chain))
+}
+
+func (bc *Blockchain) Supply() *Supply {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
+	balances := make(map[string]float32)
+	for _, b := range bc.chain {
+		for _, t := range b.transactions {
+			if t.Tx.SenderAddress != MINING_SENDER {
+				balances[t.Tx.SenderAddress] -= t.Tx.Value + t.Tx.Fee
+			}
+			balances[t.Tx.RecipientAddress] += t.Tx.Value
+		}
+	}
+	var circulating float32 = 0.0
+	for _, v := range balances {
+		if v > 0 {
+			circulating += v
+		}
+	}
+
+	height := bc.height()
+	return &Supply{
+		Height:            height,
+		BlockReward:       bc.blockReward(),
+		IssuedRewards:     issuedRewards(bc.chain),
+		CirculatingSupply: circulating,
+		MaxSupply:         bc.network.Emission.MaxSupply,
+		NextHalvingHeight: bc.network.Emission.NextHalvingHeight(height),
+	}
+}
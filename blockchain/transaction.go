@@ -1,9 +1,7 @@
 package blockchain
 
 import (
-	"crypto/ecdsa"
-	"crypto/sha256"
-	"encoding/json"
+	"fmt"
 	. "goblockchain/common"
 )
 
@@ -15,12 +13,80 @@ func NewTransaction(sender string, recipient string, value float32) *BlockTransa
 	return t
 }
 
-func VerifyTransaction(senderPublicKey *ecdsa.PublicKey, sig *Signature, t *BlockTransaction) bool {
-	m, _ := json.Marshal(t)
-	h := sha256.Sum256([]byte(m))
-	return ecdsa.Verify(senderPublicKey, h[:], sig.R, sig.S)
+// VerifyTransaction checks sig is the signature of t by senderPublicKey,
+// with the key's scheme, and that the key is the one of the sender.
+func VerifyTransaction(senderPublicKey *PublicKey, sig *Signature, t *BlockTransaction) bool {
+	if senderPublicKey == nil || len(senderPublicKey.Key) == 0 ||
+		sig == nil || sig.R == nil || sig.S == nil {
+		return false
+	}
+	if !IsAddressOfPublicKey(t.SenderAddress, senderPublicKey) {
+		return false
+	}
+	h := TransactionSigningHash(t)
+	return senderPublicKey.Scheme.Verify(senderPublicKey, h[:], sig)
 }
 
+// CheckTransactionSignature verifies the signature of t as
+// VerifyTransaction does, telling why it is rejected: a key off its curve,
+// a malformed or high-S signature, or one that does not verify. The
+// signatures of a multisig transaction are checked by
+// CheckMultisigSignatures.
+func CheckTransactionSignature(t *Transaction) error {
+	if t.Multisig != nil || t.Signatures != nil {
+		return CheckMultisigSignatures(t)
+	}
+	if t.SenderPublicKey == nil || t.Signature == nil {
+		return ErrInvalidSignature
+	}
+	scheme := t.SenderPublicKey.Scheme
+	if _, err := scheme.ParsePublicKey(t.SenderPublicKey.Key); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if err := scheme.CheckSignature(t.Signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if !VerifyTransaction(t.SenderPublicKey, t.Signature, &t.Tx) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
 
-
-
+// CheckMultisigSignatures verifies that a multisig transaction carries a
+// valid policy, whose address is the sender's, and exactly M valid
+// signatures lined up with its keys. Any more would let anyone holding the
+// transaction drop one and change its id.
+func CheckMultisigSignatures(t *Transaction) error {
+	ms := t.Multisig
+	if ms == nil || t.SenderPublicKey != nil || t.Signature != nil {
+		return fmt.Errorf("%w: a multisig transaction carries a policy and no single key", ErrInvalidSignature)
+	}
+	if err := ms.Check(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if !IsAddressOfMultisig(t.Tx.SenderAddress, ms) {
+		return ErrSenderKeyMismatch
+	}
+	if len(t.Signatures) != len(ms.Keys) {
+		return fmt.Errorf("%w: %d signatures for %d keys", ErrInvalidSignature, len(t.Signatures), len(ms.Keys))
+	}
+	h := TransactionSigningHash(&t.Tx)
+	signed := 0
+	for i, sig := range t.Signatures {
+		if sig == nil {
+			continue
+		}
+		k := ms.Keys[i]
+		if err := k.Scheme.CheckSignature(sig); err != nil {
+			return fmt.Errorf("%w: key %d: %v", ErrInvalidSignature, i, err)
+		}
+		if !k.Scheme.Verify(k, h[:], sig) {
+			return fmt.Errorf("%w: key %d", ErrInvalidSignature, i)
+		}
+		signed++
+	}
+	if signed != ms.M {
+		return fmt.Errorf("%w: %d signatures, %d of %d required", ErrInvalidSignature, signed, ms.M, len(ms.Keys))
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package blockchain
+
+import (
+	"errors"
+	. "goblockchain/common"
+	"goblockchain/wallet"
+	"testing"
+)
+
+// cosigners are wallets of the three schemes and their 2-of-3 policy, in
+// the order of its keys.
+func cosigners(t *testing.T) ([]*wallet.Wallet, *Multisig) {
+	t.Helper()
+	var wallets []*wallet.Wallet
+	var keys []*PublicKey
+	for _, scheme := range []SignatureScheme{P256, SECP256K1, ED25519} {
+		w, err := wallet.NewSchemeWallet(scheme, REGTEST)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wallets = append(wallets, w)
+		keys = append(keys, w.PublicKey())
+	}
+	ms, err := NewMultisig(2, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ordered := make([]*wallet.Wallet, len(wallets))
+	for _, w := range wallets {
+		ordered[ms.IndexOf(w.PublicKey())] = w
+	}
+	return ordered, ms
+}
+
+func TestCheckMultisigSignatures(t *testing.T) {
+	wallets, ms := cosigners(t)
+	outsider := wallet.NewWallet(REGTEST)
+	recipient := outsider.BlockchainAddress()
+
+	// proposal is unsigned, signed builds it and co-signs it with signers
+	proposal := func() *Transaction {
+		return &Transaction{
+			Multisig:   ms,
+			Signatures: make([]*Signature, len(ms.Keys)),
+			Tx:         BlockTransaction{SenderAddress: ms.Address(REGTEST), RecipientAddress: recipient, Value: 1, Nonce: 3},
+		}
+	}
+	signed := func(signers ...*wallet.Wallet) *Transaction {
+		tx := proposal()
+		for _, w := range signers {
+			if err := w.CoSign(tx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return tx
+	}
+
+	tests := []struct {
+		name string
+		tx   func() *Transaction
+		err  error
+	}{
+		{"first and second", func() *Transaction { return signed(wallets[0], wallets[1]) }, nil},
+		{"first and last", func() *Transaction { return signed(wallets[0], wallets[2]) }, nil},
+		{"second and last", func() *Transaction { return signed(wallets[2], wallets[1]) }, nil},
+		{"one signature", func() *Transaction { return signed(wallets[1]) }, ErrInvalidSignature},
+		{"three signatures", func() *Transaction { return signed(wallets...) }, ErrInvalidSignature},
+		{"signatures swapped", func() *Transaction {
+			tx := signed(wallets[0], wallets[1])
+			tx.Signatures[0], tx.Signatures[1] = tx.Signatures[1], tx.Signatures[0]
+			return tx
+		}, ErrInvalidSignature},
+		{"changed after signing", func() *Transaction {
+			tx := signed(wallets[0], wallets[1])
+			tx.Tx.Nonce++
+			return tx
+		}, ErrInvalidSignature},
+		{"signatures missing", func() *Transaction {
+			tx := signed(wallets[0], wallets[1])
+			tx.Signatures = tx.Signatures[:2]
+			return tx
+		}, ErrInvalidSignature},
+		{"single key too", func() *Transaction {
+			tx := signed(wallets[0], wallets[1])
+			tx.SenderPublicKey = wallets[0].PublicKey()
+			return tx
+		}, ErrInvalidSignature},
+		{"other sender", func() *Transaction {
+			tx := signed()
+			tx.Tx.SenderAddress = outsider.BlockchainAddress()
+			wallets[0].CoSign(tx)
+			wallets[1].CoSign(tx)
+			return tx
+		}, ErrSenderKeyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckTransactionSignature(tt.tx()); !errors.Is(err, tt.err) {
+				t.Errorf("CheckTransactionSignature() = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	if err := outsider.CoSign(proposal()); !errors.Is(err, wallet.ErrNotCosigner) {
+		t.Errorf("CoSign() by an outsider = %v, want %v", err, wallet.ErrNotCosigner)
+	}
+}
@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	. "goblockchain/common"
+)
+
+const (
+	TX_STATUS_PENDING   = "pending"
+	TX_STATUS_CONFIRMED = "confirmed"
+	TX_STATUS_UNKNOWN   = "unknown"
+)
+
+type txLocation struct {
+	height int
+	index  int
+}
+
+type TransactionStatus struct {
+	ID            string       `json:"id"`
+	Status        string       `json:"status"`
+	BlockHash     string       `json:"block_hash,omitempty"`
+	Height        int          `json:"height,omitempty"`
+	Confirmations int          `json:"confirmations,omitempty"`
+	Transaction   *Transaction `json:"transaction,omitempty"`
+}
+
+// BlockTransactionID returns the ID of the i-th transaction of the block at
+// height. Coinbases carry no signature, so the height is mixed into their
+// ID to tell apart identical rewards paid to the same miner.
+func BlockTransactionID(t *Transaction, height int, i int) string {
+	if i != 0 || t.Tx.SenderAddress != MINING_SENDER {
+		return TransactionID(t)
+	}
+	h := sha256.New()
+	h.Write(EncodeSignedTransaction(t))
+	binary.Write(h, binary.BigEndian, int64(height))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (bc *Blockchain) indexBlock(b *Block, height int) {
+	b.height = height
+	bc.blockIndex[b.Hash()] = height
+	for i, t := range b.transactions {
+		bc.txIndex[BlockTransactionID(t, height, i)] = txLocation{height, i}
+	}
+}
+
+func (bc *Blockchain) unindexBlock(b *Block, height int) {
+	delete(bc.blockIndex, b.Hash())
+	for i, t := range b.transactions {
+		delete(bc.txIndex, BlockTransactionID(t, height, i))
+	}
+}
+
+func (bc *Blockchain) TransactionStatus(id string) *TransactionStatus {
+	bc.muxChain.RLock()
+	defer bc.muxChain.RUnlock()
+	if loc, ok := bc.txIndex[id]; ok {
+		b := bc.chain[loc.height]
+		return &TransactionStatus{
+			ID:            id,
+			Status:        TX_STATUS_CONFIRMED,
+			BlockHash:     fmt.Sprintf("%x", b.Hash()),
+			Height:        loc.height,
+			Confirmations: bc.height() - loc.height + 1,
+			Transaction:   b.transactions[loc.index],
+		}
+	}
+	for _, t := range bc.transactionPool {
+		if TransactionID(t) == id {
+			return &TransactionStatus{ID: id, Status: TX_STATUS_PENDING, Transaction: t}
+		}
+	}
+	return &TransactionStatus{ID: id, Status: TX_STATUS_UNKNOWN}
+}
@@ -0,0 +1,126 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	. "goblockchain/common"
+)
+
+var (
+	ErrInvalidGenesis       = errors.New("genesis block does not belong to this network")
+	ErrInvalidPreviousHash  = errors.New("previous hash does not match")
+	ErrInvalidProof         = errors.New("proof of work not satisfied")
+	ErrInvalidCoinbase      = errors.New("invalid coinbase")
+	ErrMintTransaction      = errors.New("mint transactions are only created by mining")
+	ErrInvalidAmount        = errors.New("invalid value or fee")
+	ErrInvalidSignature     = errors.New("invalid signature")
+	ErrSenderKeyMismatch    = errors.New("sender address does not match public key or multisig policy")
+	ErrInsufficientFunds    = errors.New("insufficient funds")
+	ErrDuplicateTransaction = errors.New("duplicate transaction")
+	ErrInvalidNonce         = errors.New("nonce out of sequence")
+)
+
+// ValidationError locates the first invalid block, and transaction within
+// it, found while replaying a chain. TxIndex is -1 when the block itself is
+// invalid.
+type ValidationError struct {
+	Height  int
+	TxIndex int
+	Err     error
+}
+
+func (e *ValidationError) Error() string {
+	if e.TxIndex < 0 {
+		return fmt.Sprintf("block %d: %v", e.Height, e.Err)
+	}
+	return fmt.Sprintf("block %d, transaction %d: %v", e.Height, e.TxIndex, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ledger is the state built by replaying transactions: the balance of every
+// address and the nonce the next transaction of every sender carries. A
+// ledger with a parent only holds the changes of its own transactions to
+// the state of the parent, as the one of the pool does to the chain's.
+type ledger struct {
+	parent   *ledger
+	balances map[string]float32
+	nonces   map[string]uint64
+}
+
+func newLedger(parent *ledger) *ledger {
+	return &ledger{
+		parent:   parent,
+		balances: make(map[string]float32),
+		nonces:   make(map[string]uint64),
+	}
+}
+
+func (l *ledger) balance(address string) float32 {
+	if l.parent != nil {
+		return l.parent.balance(address) + l.balances[address]
+	}
+	return l.balances[address]
+}
+
+func (l *ledger) nonce(address string) uint64 {
+	if l.parent != nil {
+		return l.parent.nonce(address) + l.nonces[address]
+	}
+	return l.nonces[address]
+}
+
+func (l *ledger) apply(t *Transaction) {
+	if t.Tx.SenderAddress != MINING_SENDER {
+		l.balances[t.Tx.SenderAddress] -= t.Tx.Value + t.Tx.Fee
+		l.nonces[t.Tx.SenderAddress]++
+	}
+	l.balances[t.Tx.RecipientAddress] += t.Tx.Value
+}
+
+// revert undoes apply, for the transactions of a disconnected block.
+func (l *ledger) revert(t *Transaction) {
+	if t.Tx.SenderAddress != MINING_SENDER {
+		l.balances[t.Tx.SenderAddress] += t.Tx.Value + t.Tx.Fee
+		l.nonces[t.Tx.SenderAddress]--
+	}
+	l.balances[t.Tx.RecipientAddress] -= t.Tx.Value
+}
+
+// check validates a regular, signed transaction against the ledger.
+func (l *ledger) check(network *Network, t *Transaction) error {
+	if t.Tx.SenderAddress == MINING_SENDER {
+		return ErrMintTransaction
+	}
+	if err := network.ValidateAddress(t.Tx.SenderAddress); err != nil {
+		return fmt.Errorf("sender: %w", err)
+	}
+	if err := network.ValidateAddress(t.Tx.RecipientAddress); err != nil {
+		return fmt.Errorf("recipient: %w", err)
+	}
+	if !(t.Tx.Value > 0) || !(t.Tx.Fee >= 0) {
+		return ErrInvalidAmount
+	}
+	// a multisig address is only spent with the signatures of its policy
+	if network.IsMultisigAddress(t.Tx.SenderAddress) != (t.Multisig != nil) {
+		return ErrSenderKeyMismatch
+	}
+	if t.Multisig == nil && !IsAddressOfPublicKey(t.Tx.SenderAddress, t.SenderPublicKey) {
+		return ErrSenderKeyMismatch
+	}
+	if err := CheckTransactionSignature(t); err != nil {
+		return err
+	}
+	// a nonce already used is a replay, a later one would leave a gap
+	if next := l.nonce(t.Tx.SenderAddress); t.Tx.Nonce < next {
+		return fmt.Errorf("%w: nonce %d already used", ErrDuplicateTransaction, t.Tx.Nonce)
+	} else if t.Tx.Nonce > next {
+		return fmt.Errorf("%w: %d, expected %d", ErrInvalidNonce, t.Tx.Nonce, next)
+	}
+	if l.balance(t.Tx.SenderAddress) < t.Tx.Value+t.Tx.Fee {
+		return ErrInsufficientFunds
+	}
+	return nil
+}
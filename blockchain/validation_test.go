@@ -0,0 +1,188 @@
+package blockchain
+
+import (
+	"errors"
+	. "goblockchain/common"
+	"goblockchain/wallet"
+	"testing"
+)
+
+// newTestChain is a regtest chain of one mined block, whose reward is paid
+// to miner.
+func newTestChain(t *testing.T, miner *wallet.Wallet) *Blockchain {
+	t.Helper()
+	bc := NewBlockchain(miner.BlockchainAddress(), 0, REGTEST)
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+	return bc
+}
+
+// mineBlock builds a block of transactions on top of previous, with a
+// valid proof of work.
+func mineBlock(bc *Blockchain, previous *Block, transactions []*Transaction) *Block {
+	nonce := 0
+	for !bc.ValidProof(nonce, previous.Hash(), transactions, bc.network.Difficulty) {
+		nonce++
+	}
+	return NewBlock(nonce, previous.Hash(), transactions)
+}
+
+func signedTransaction(t *testing.T, w *wallet.Wallet, recipient string, value float32, nonce uint64) *Transaction {
+	t.Helper()
+	tx := w.CreateTransaction(recipient, value, 0, nonce)
+	if err := w.SignTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestLedgerNonces(t *testing.T) {
+	// secp256k1 signs deterministically: equal payments only differ by nonce
+	miner, _ := wallet.NewSchemeWallet(SECP256K1, REGTEST)
+	recipient := wallet.NewWallet(REGTEST).BlockchainAddress()
+	bc := newTestChain(t, miner)
+
+	first := signedTransaction(t, miner, recipient, 1, 0)
+	tests := []struct {
+		name string
+		tx   *Transaction
+		err  error
+	}{
+		{"first", first, nil},
+		{"replay", first, ErrDuplicateTransaction},
+		{"gap", signedTransaction(t, miner, recipient, 1, 2), ErrInvalidNonce},
+		{"same payment, next nonce", signedTransaction(t, miner, recipient, 1, 1), nil},
+		{"nonce already used", signedTransaction(t, miner, recipient, 2, 1), ErrDuplicateTransaction},
+		{"insufficient funds", signedTransaction(t, miner, recipient, 1000, 2), ErrInsufficientFunds},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := bc.SubmitTransaction(tt.tx); !errors.Is(err, tt.err) {
+				t.Errorf("SubmitTransaction() = %v, want %v", err, tt.err)
+			}
+		})
+	}
+	if got := bc.NextNonce(miner.BlockchainAddress()); got != 2 {
+		t.Errorf("NextNonce() = %d, want 2", got)
+	}
+
+	// mined, the transactions still count
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+	if got := bc.NextNonce(miner.BlockchainAddress()); got != 2 {
+		t.Errorf("NextNonce() after mining = %d, want 2", got)
+	}
+	if err := bc.CheckTransaction(first); !errors.Is(err, ErrDuplicateTransaction) {
+		t.Errorf("CheckTransaction() of a mined transaction = %v, want %v", err, ErrDuplicateTransaction)
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	miner := wallet.NewWallet(REGTEST)
+	recipient := wallet.NewWallet(REGTEST).BlockchainAddress()
+	bc := newTestChain(t, miner)
+	chain := bc.Chain()
+	last := chain[len(chain)-1]
+	reward := func(transactions ...*Transaction) []*Transaction {
+		return append([]*Transaction{NewCoinbase(miner.BlockchainAddress(), bc.BlockReward(), transactions)}, transactions...)
+	}
+	paid := signedTransaction(t, miner, recipient, 1, 0)
+
+	tests := []struct {
+		name    string
+		chain   func() []*Block
+		height  int
+		txIndex int
+		err     error
+	}{
+		{
+			name:  "valid",
+			chain: func() []*Block { return append(chain, mineBlock(bc, last, reward(paid))) },
+		},
+		{
+			name:    "wrong genesis",
+			chain:   func() []*Block { return []*Block{NewGenesisBlock(TESTNET)} },
+			txIndex: -1,
+			err:     ErrInvalidGenesis,
+		},
+		{
+			name: "broken link",
+			chain: func() []*Block {
+				return append(chain, mineBlock(bc, chain[0], reward()))
+			},
+			height:  2,
+			txIndex: -1,
+			err:     ErrInvalidPreviousHash,
+		},
+		{
+			name: "no proof of work",
+			chain: func() []*Block {
+				b := mineBlock(bc, last, reward())
+				for bc.ValidProof(b.nonce, b.previousHash, b.transactions, bc.network.Difficulty) {
+					b.nonce++
+				}
+				return append(chain, b)
+			},
+			height:  2,
+			txIndex: -1,
+			err:     ErrInvalidProof,
+		},
+		{
+			name: "replay in the same block",
+			chain: func() []*Block {
+				return append(chain, mineBlock(bc, last, reward(paid, paid)))
+			},
+			height:  2,
+			txIndex: 2,
+			err:     ErrDuplicateTransaction,
+		},
+		{
+			name: "replay in a later block",
+			chain: func() []*Block {
+				b := mineBlock(bc, last, reward(paid))
+				return append(chain, b, mineBlock(bc, b, reward(paid)))
+			},
+			height:  3,
+			txIndex: 1,
+			err:     ErrDuplicateTransaction,
+		},
+		{
+			name: "nonce gap",
+			chain: func() []*Block {
+				return append(chain, mineBlock(bc, last, reward(signedTransaction(t, miner, recipient, 1, 1))))
+			},
+			height:  2,
+			txIndex: 1,
+			err:     ErrInvalidNonce,
+		},
+		{
+			name: "insufficient funds",
+			chain: func() []*Block {
+				return append(chain, mineBlock(bc, last, reward(signedTransaction(t, miner, recipient, 1000, 0))))
+			},
+			height:  2,
+			txIndex: 1,
+			err:     ErrInsufficientFunds,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bc.VerifyChain(tt.chain())
+			if tt.err == nil {
+				if err != nil {
+					t.Fatalf("VerifyChain() = %v, want nil", err)
+				}
+				return
+			}
+			var ve *ValidationError
+			if !errors.As(err, &ve) || !errors.Is(err, tt.err) {
+				t.Fatalf("VerifyChain() = %v, want %v", err, tt.err)
+			}
+			if ve.Height != tt.height || ve.TxIndex != tt.txIndex {
+				t.Errorf("VerifyChain() at block %d, transaction %d, want %d, %d", ve.Height, ve.TxIndex, tt.height, tt.txIndex)
+			}
+		})
+	}
+}
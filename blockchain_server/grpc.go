@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	. "goblockchain/blockchain"
+	pb "goblockchain/blockchainpb"
+	"goblockchain/common"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"net"
+)
+
+// GRPC_PORT_OFFSET places the gRPC listener of a node at its HTTP port plus
+// the offset, unless --grpc-port says otherwise.
+const GRPC_PORT_OFFSET = 10000
+
+type grpcServer struct {
+	pb.UnimplementedBlockchainServer
+	bcs *BlockchainServer
+}
+
+func transactionToProto(t *common.Transaction, id string) *pb.Transaction {
+	p := &pb.Transaction{
+		Id:               id,
+		SenderPublicKey:  common.EncodePublicKey(t.SenderPublicKey),
+		Signature:        common.EncodeSignature(t.Signature),
+		Multisig:         common.EncodeMultisig(t.Multisig),
+		SenderAddress:    t.Tx.SenderAddress,
+		RecipientAddress: t.Tx.RecipientAddress,
+		Value:            t.Tx.Value,
+		Fee:              t.Tx.Fee,
+		Nonce:            t.Tx.Nonce,
+	}
+	for _, s := range t.Signatures {
+		p.Signatures = append(p.Signatures, common.EncodeSignature(s))
+	}
+	return p
+}
+
+func transactionFromProto(p *pb.Transaction) (*common.Transaction, error) {
+	publicKey, err := common.DecodePublicKey(p.SenderPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := common.DecodeSignature(p.Signature)
+	if err != nil {
+		return nil, err
+	}
+	multisig, err := common.DecodeMultisig(p.Multisig)
+	if err != nil {
+		return nil, err
+	}
+	t := &common.Transaction{SenderPublicKey: publicKey, Signature: signature, Multisig: multisig}
+	for _, b := range p.Signatures {
+		s, err := common.DecodeSignature(b)
+		if err != nil {
+			return nil, err
+		}
+		t.Signatures = append(t.Signatures, s)
+	}
+	t.Tx.SenderAddress = p.SenderAddress
+	t.Tx.RecipientAddress = p.RecipientAddress
+	t.Tx.Value = p.Value
+	t.Tx.Fee = p.Fee
+	t.Tx.Nonce = p.Nonce
+	return t, nil
+}
+
+func blockToProto(b *Block) *pb.Block {
+	p := &pb.Block{
+		Hash:         fmt.Sprintf("%x", b.Hash()),
+		Height:       int64(b.Height()),
+		Timestamp:    b.Timestamp(),
+		Nonce:        int64(b.Nonce()),
+		PreviousHash: fmt.Sprintf("%x", b.PreviousHash()),
+	}
+	for i, t := range b.Transactions() {
+		p.Transactions = append(p.Transactions, transactionToProto(t, BlockTransactionID(t, b.Height(), i)))
+	}
+	return p
+}
+
+func (s *grpcServer) GetChainInfo(ctx context.Context, req *pb.GetChainInfoRequest) (*pb.ChainInfo, error) {
+	bc := s.bcs.GetBlockchain()
+	return &pb.ChainInfo{
+		Network:     bc.Network().Name,
+		Height:      int64(bc.Height()),
+		Tip:         fmt.Sprintf("%x", bc.LastHash()),
+		Difficulty:  int32(bc.Network().Difficulty),
+		BlockReward: bc.BlockReward(),
+		Mempool:     int32(len(bc.TransactionPool())),
+		Peers:       int32(len(bc.Neighbors())),
+	}, nil
+}
+
+func (s *grpcServer) GetBlock(ctx context.Context, req *pb.GetBlockRequest) (*pb.Block, error) {
+	bc := s.bcs.GetBlockchain()
+	var b *Block
+	switch sel := req.Selector.(type) {
+	case *pb.GetBlockRequest_Height:
+		b = bc.BlockByHeight(int(sel.Height))
+	case *pb.GetBlockRequest_Hash:
+		h, err := hex.DecodeString(sel.Hash)
+		if err != nil || len(h) != 32 {
+			return nil, status.Error(codes.InvalidArgument, "invalid hash")
+		}
+		var hash [32]byte
+		copy(hash[:], h)
+		b = bc.BlockByHash(hash)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "height or hash required")
+	}
+	if b == nil {
+		return nil, status.Error(codes.NotFound, "block not found")
+	}
+	return blockToProto(b), nil
+}
+
+func (s *grpcServer) GetBlocks(ctx context.Context, req *pb.GetBlocksRequest) (*pb.GetBlocksResponse, error) {
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = PAGE_DEFAULT_LIMIT
+	}
+	if limit > PAGE_MAX_LIMIT {
+		limit = PAGE_MAX_LIMIT
+	}
+	bc := s.bcs.GetBlockchain()
+	res := &pb.GetBlocksResponse{Height: int64(bc.Height())}
+	for _, b := range bc.Blocks(int(req.From), limit) {
+		res.Blocks = append(res.Blocks, blockToProto(b))
+	}
+	return res, nil
+}
+
+func (s *grpcServer) GetTransaction(ctx context.Context, req *pb.GetTransactionRequest) (*pb.TransactionStatus, error) {
+	ts := s.bcs.GetBlockchain().TransactionStatus(req.Id)
+	res := &pb.TransactionStatus{
+		Id:            ts.ID,
+		Status:        ts.Status,
+		BlockHash:     ts.BlockHash,
+		Height:        int64(ts.Height),
+		Confirmations: int64(ts.Confirmations),
+	}
+	if ts.Transaction != nil {
+		res.Transaction = transactionToProto(ts.Transaction, ts.ID)
+	}
+	return res, nil
+}
+
+func (s *grpcServer) GetMempool(ctx context.Context, req *pb.GetMempoolRequest) (*pb.GetMempoolResponse, error) {
+	res := &pb.GetMempoolResponse{}
+	for _, t := range s.bcs.GetBlockchain().TransactionPool() {
+		res.Transactions = append(res.Transactions, transactionToProto(t, common.TransactionID(t)))
+	}
+	return res, nil
+}
+
+func (s *grpcServer) GetBalance(ctx context.Context, req *pb.GetBalanceRequest) (*pb.Balance, error) {
+	if req.Address == "" {
+		return nil, status.Error(codes.InvalidArgument, "address required")
+	}
+	address, err := s.bcs.network.ParseAddress(req.Address)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	bc := s.bcs.GetBlockchain()
+	return &pb.Balance{Address: address, Amount: bc.CalculateTotalAmount(address), Nonce: bc.NextNonce(address)}, nil
+}
+
+func (s *grpcServer) SubmitTransaction(ctx context.Context, req *pb.SubmitTransactionRequest) (*pb.SubmitTransactionResponse, error) {
+	if req.Transaction == nil {
+		return nil, status.Error(codes.InvalidArgument, "transaction required")
+	}
+	t, err := transactionFromProto(req.Transaction)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.bcs.GetBlockchain().SubmitTransaction(t); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &pb.SubmitTransactionResponse{Id: common.TransactionID(t)}, nil
+}
+
+func (s *grpcServer) SubscribeBlocks(req *pb.SubscribeBlocksRequest, stream pb.Blockchain_SubscribeBlocksServer) error {
+	events, unsubscribe := s.bcs.GetBlockchain().Subscribe()
+	defer unsubscribe()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "subscriber fell behind")
+			}
+			var eventType pb.BlockEvent_Type
+			switch e.Type {
+			case EVENT_BLOCK_CONNECTED:
+				eventType = pb.BlockEvent_CONNECTED
+			case EVENT_BLOCK_DISCONNECTED:
+				eventType = pb.BlockEvent_DISCONNECTED
+			default:
+				continue
+			}
+			if err := stream.Send(&pb.BlockEvent{Type: eventType, Block: blockToProto(e.Block)}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *grpcServer) SubscribeMempool(req *pb.SubscribeMempoolRequest, stream pb.Blockchain_SubscribeMempoolServer) error {
+	events, unsubscribe := s.bcs.GetBlockchain().Subscribe()
+	defer unsubscribe()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "subscriber fell behind")
+			}
+			if e.Type != EVENT_TX_ADDED {
+				continue
+			}
+			if err := stream.Send(transactionToProto(e.Transaction, common.TransactionID(e.Transaction))); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// RunGRPC serves the gRPC API on grpcPort until the listener fails.
+func (bcs *BlockchainServer) RunGRPC(grpcPort uint16) {
+	lis, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", grpcPort))
+	if err != nil {
+		log.Printf("ERROR: gRPC listen: %v", err)
+		return
+	}
+	s := grpc.NewServer()
+	pb.RegisterBlockchainServer(s, &grpcServer{bcs: bcs})
+	log.Printf("gRPC listening on localhost:%d", grpcPort)
+	if err := s.Serve(lis); err != nil {
+		log.Printf("ERROR: gRPC serve: %v", err)
+	}
+}
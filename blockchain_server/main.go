@@ -2,6 +2,8 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"goblockchain/common"
 	"log"
 )
 
@@ -10,8 +12,41 @@ func init() {
 }
 
 func main() {
-	port := flag.Uint("port", 5000, "TCP port for BlockchainServer")
+	port := flag.Uint("port", 0, "TCP port for BlockchainServer (default: network port)")
+	grpcPort := flag.Uint("grpc-port", 0, "TCP port for the gRPC API (default: port + 10000)")
+	networkName := flag.String("network", common.MAINNET.Name, "Network profile: mainnet, testnet or regtest")
+	initialReward := flag.Float64("initial-reward", 0, "Initial block reward (default: network schedule)")
+	halvingInterval := flag.Int("halving-interval", 0, "Blocks between reward halvings (default: network schedule)")
+	maxSupply := flag.Float64("max-supply", 0, "Maximum coin supply (default: network schedule)")
+	faucet := flag.Bool("faucet", false, "Serve POST /faucet from the miner's wallet (dev networks only)")
+	webhooks := flag.String("webhooks", "", "File the webhook registrations are kept in (default: webhooks-<network>-<port>.json)")
 	flag.Parse()
-	app := NewBlockchainServer(uint16(*port))
+	profile, err := common.NetworkByName(*networkName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	network := *profile
+	if *initialReward > 0 {
+		network.Emission.InitialReward = float32(*initialReward)
+	}
+	if *halvingInterval > 0 {
+		network.Emission.HalvingInterval = *halvingInterval
+	}
+	if *maxSupply > 0 {
+		network.Emission.MaxSupply = float32(*maxSupply)
+	}
+	if *faucet && !network.Faucet {
+		log.Fatalf("faucet is not available on %s", network.Name)
+	}
+	if *port == 0 {
+		*port = uint(network.DefaultPort)
+	}
+	if *grpcPort == 0 {
+		*grpcPort = *port + GRPC_PORT_OFFSET
+	}
+	if *webhooks == "" {
+		*webhooks = fmt.Sprintf("webhooks-%s-%d.json", network.Name, *port)
+	}
+	app := NewBlockchainServer(uint16(*port), uint16(*grpcPort), &network, *faucet, *webhooks)
 	app.Run()
 }
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	. "goblockchain/blockchain"
+	"goblockchain/common"
+	"io"
+	"log"
+	"net/http"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	RPC_PARSE_ERROR        = -32700
+	RPC_INVALID_REQUEST    = -32600
+	RPC_METHOD_NOT_FOUND   = -32601
+	RPC_INVALID_PARAMS     = -32602
+	RPC_INTERNAL_ERROR     = -32603
+	RPC_TRANSACTION_REJECT = -32000
+	RPC_NOT_FOUND          = -32001
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcMethod func(bcs *BlockchainServer, params json.RawMessage) (interface{}, *rpcError)
+
+var rpcMethods = map[string]rpcMethod{
+	"getChainInfo":       rpcGetChainInfo,
+	"getBlockByHeight":   rpcGetBlockByHeight,
+	"getBlockByHash":     rpcGetBlockByHash,
+	"getTransaction":     rpcGetTransaction,
+	"getBalance":         rpcGetBalance,
+	"getNonce":           rpcGetNonce,
+	"sendRawTransaction": rpcSendRawTransaction,
+	"getMempool":         rpcGetMempool,
+	"getPeers":           rpcGetPeers,
+}
+
+var rpcNullID = json.RawMessage("null")
+
+// decodeParams fills targets from either positional (array) or named
+// (object) params, names giving the key of each target.
+func decodeParams(params json.RawMessage, names []string, targets ...interface{}) *rpcError {
+	invalid := &rpcError{RPC_INVALID_PARAMS, fmt.Sprintf("expected params %v", names)}
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 {
+		return invalid
+	}
+	var fields []json.RawMessage
+	if params[0] == '[' {
+		if err := json.Unmarshal(params, &fields); err != nil || len(fields) != len(targets) {
+			return invalid
+		}
+	} else {
+		var named map[string]json.RawMessage
+		if err := json.Unmarshal(params, &named); err != nil {
+			return invalid
+		}
+		for _, n := range names {
+			f, ok := named[n]
+			if !ok {
+				return invalid
+			}
+			fields = append(fields, f)
+		}
+	}
+	for i, f := range fields {
+		if err := json.Unmarshal(f, targets[i]); err != nil {
+			return invalid
+		}
+	}
+	return nil
+}
+
+func rpcGetChainInfo(bcs *BlockchainServer, params json.RawMessage) (interface{}, *rpcError) {
+	bc := bcs.GetBlockchain()
+	return struct {
+		Network     string  `json:"network"`
+		Height      int     `json:"height"`
+		Tip         string  `json:"tip"`
+		Difficulty  int     `json:"difficulty"`
+		BlockReward float32 `json:"block_reward"`
+		Mempool     int     `json:"mempool"`
+		Peers       int     `json:"peers"`
+	}{
+		Network:     bc.Network().Name,
+		Height:      bc.Height(),
+		Tip:         fmt.Sprintf("%x", bc.LastHash()),
+		Difficulty:  bc.Network().Difficulty,
+		BlockReward: bc.BlockReward(),
+		Mempool:     len(bc.TransactionPool()),
+		Peers:       len(bc.Neighbors()),
+	}, nil
+}
+
+func rpcGetBlockByHeight(bcs *BlockchainServer, params json.RawMessage) (interface{}, *rpcError) {
+	var height int
+	if err := decodeParams(params, []string{"height"}, &height); err != nil {
+		return nil, err
+	}
+	b := bcs.GetBlockchain().BlockByHeight(height)
+	if b == nil {
+		return nil, &rpcError{RPC_NOT_FOUND, "block not found"}
+	}
+	return b, nil
+}
+
+func rpcGetBlockByHash(bcs *BlockchainServer, params json.RawMessage) (interface{}, *rpcError) {
+	var hash string
+	if err := decodeParams(params, []string{"hash"}, &hash); err != nil {
+		return nil, err
+	}
+	h, err := hex.DecodeString(hash)
+	if err != nil || len(h) != 32 {
+		return nil, &rpcError{RPC_INVALID_PARAMS, "invalid hash"}
+	}
+	var key [32]byte
+	copy(key[:], h)
+	b := bcs.GetBlockchain().BlockByHash(key)
+	if b == nil {
+		return nil, &rpcError{RPC_NOT_FOUND, "block not found"}
+	}
+	return b, nil
+}
+
+func rpcGetTransaction(bcs *BlockchainServer, params json.RawMessage) (interface{}, *rpcError) {
+	var id string
+	if err := decodeParams(params, []string{"id"}, &id); err != nil {
+		return nil, err
+	}
+	status := bcs.GetBlockchain().TransactionStatus(id)
+	if status.Status == TX_STATUS_UNKNOWN {
+		return nil, &rpcError{RPC_NOT_FOUND, "transaction not found"}
+	}
+	return status, nil
+}
+
+func rpcGetBalance(bcs *BlockchainServer, params json.RawMessage) (interface{}, *rpcError) {
+	var address string
+	if err := decodeParams(params, []string{"address"}, &address); err != nil {
+		return nil, err
+	}
+	address, err := bcs.network.ParseAddress(address)
+	if err != nil {
+		return nil, &rpcError{RPC_INVALID_PARAMS, err.Error()}
+	}
+	return bcs.GetBlockchain().CalculateTotalAmount(address), nil
+}
+
+func rpcGetNonce(bcs *BlockchainServer, params json.RawMessage) (interface{}, *rpcError) {
+	var address string
+	if err := decodeParams(params, []string{"address"}, &address); err != nil {
+		return nil, err
+	}
+	address, err := bcs.network.ParseAddress(address)
+	if err != nil {
+		return nil, &rpcError{RPC_INVALID_PARAMS, err.Error()}
+	}
+	return bcs.GetBlockchain().NextNonce(address), nil
+}
+
+func rpcSendRawTransaction(bcs *BlockchainServer, params json.RawMessage) (interface{}, *rpcError) {
+	var t common.Transaction
+	if err := decodeParams(params, []string{"transaction"}, &t); err != nil {
+		return nil, err
+	}
+	if err := bcs.GetBlockchain().SubmitTransaction(&t); err != nil {
+		return nil, &rpcError{RPC_TRANSACTION_REJECT, err.Error()}
+	}
+	return common.TransactionID(&t), nil
+}
+
+func rpcGetMempool(bcs *BlockchainServer, params json.RawMessage) (interface{}, *rpcError) {
+	return bcs.GetBlockchain().TransactionPool(), nil
+}
+
+func rpcGetPeers(bcs *BlockchainServer, params json.RawMessage) (interface{}, *rpcError) {
+	return bcs.GetBlockchain().Neighbors(), nil
+}
+
+// call runs a single request. It returns nil for notifications, which get
+// no response.
+func (bcs *BlockchainServer) call(raw json.RawMessage) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+		return &rpcResponse{JSONRPC: "2.0", Error: &rpcError{RPC_INVALID_REQUEST, "invalid request"}, ID: rpcNullID}
+	}
+	result, rerr := bcs.dispatch(req)
+	if req.ID == nil {
+		return nil
+	}
+	res := &rpcResponse{JSONRPC: "2.0", ID: req.ID}
+	if rerr != nil {
+		res.Error = rerr
+		return res
+	}
+	m, err := json.Marshal(result)
+	if err != nil {
+		res.Error = &rpcError{RPC_INTERNAL_ERROR, err.Error()}
+		return res
+	}
+	res.Result = m
+	return res
+}
+
+func (bcs *BlockchainServer) dispatch(req rpcRequest) (result interface{}, rerr *rpcError) {
+	method, ok := rpcMethods[req.Method]
+	if !ok {
+		return nil, &rpcError{RPC_METHOD_NOT_FOUND, "method not found"}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("ERROR: rpc %s: %v", req.Method, r)
+			result, rerr = nil, &rpcError{RPC_INTERNAL_ERROR, "internal error"}
+		}
+	}()
+	return method(bcs, req.Params)
+}
+
+// RPC serves JSON-RPC 2.0 requests, single or batched, on POST /rpc.
+func (bcs *BlockchainServer) RPC(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		res.Header().Add("Content-Type", "application/json")
+		body, err := io.ReadAll(req.Body)
+		body = bytes.TrimSpace(body)
+		var m []byte
+		if err != nil || !json.Valid(body) {
+			m, _ = json.Marshal(&rpcResponse{JSONRPC: "2.0", Error: &rpcError{RPC_PARSE_ERROR, "parse error"}, ID: rpcNullID})
+		} else if body[0] == '[' {
+			var batch []json.RawMessage
+			json.Unmarshal(body, &batch)
+			if len(batch) == 0 {
+				m, _ = json.Marshal(&rpcResponse{JSONRPC: "2.0", Error: &rpcError{RPC_INVALID_REQUEST, "empty batch"}, ID: rpcNullID})
+			} else {
+				responses := make([]*rpcResponse, 0)
+				for _, raw := range batch {
+					if r := bcs.call(raw); r != nil {
+						responses = append(responses, r)
+					}
+				}
+				if len(responses) == 0 {
+					res.WriteHeader(http.StatusNoContent)
+					return
+				}
+				m, _ = json.Marshal(responses)
+			}
+		} else {
+			r := bcs.call(body)
+			if r == nil {
+				res.WriteHeader(http.StatusNoContent)
+				return
+			}
+			m, _ = json.Marshal(r)
+		}
+		io.WriteString(res, string(m))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
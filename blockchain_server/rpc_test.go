@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	. "goblockchain/blockchain"
+	"strings"
+	"testing"
+)
+
+func TestRPCGetTransaction(t *testing.T) {
+	bcs := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+	coinbase := bc.AddressTransactions(bcs.wallet.BlockchainAddress(), 0, 1)[0].ID
+	tests := []struct {
+		name   string
+		params string
+		code   int
+	}{
+		{"confirmed", fmt.Sprintf("[%q]", coinbase), 0},
+		{"unknown", fmt.Sprintf("[%q]", strings.Repeat("00", 32)), RPC_NOT_FOUND},
+		{"no id", "[]", RPC_INVALID_PARAMS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := bcs.call(json.RawMessage(`{"jsonrpc":"2.0","method":"getTransaction","params":` + tt.params + `,"id":1}`))
+			if tt.code != 0 {
+				if res.Error == nil || res.Error.Code != tt.code {
+					t.Errorf("error = %+v, want the code %d", res.Error, tt.code)
+				}
+				return
+			}
+			var status TransactionStatus
+			if res.Error != nil {
+				t.Fatalf("error = %+v", res.Error)
+			}
+			if err := json.Unmarshal(res.Result, &status); err != nil || status.Status != TX_STATUS_CONFIRMED {
+				t.Errorf("status = %+v, %v, want %s", status, err, TX_STATUS_CONFIRMED)
+			}
+		})
+	}
+}
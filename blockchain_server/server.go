@@ -1,25 +1,56 @@
 package main
 
 import (
+	_ "embed"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	. "goblockchain/blockchain"
 	"goblockchain/common"
 	"goblockchain/wallet"
+	"golang.org/x/net/websocket"
 	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
-var cache map[string]*Blockchain = make(map[string]*Blockchain)
+const (
+	FAUCET_AMOUNT       = 10.0
+	FAUCET_COOLDOWN_MIN = 10
+
+	PAGE_DEFAULT_LIMIT = 20
+	PAGE_MAX_LIMIT     = 100
+)
+
+//go:embed openapi.yaml
+var openapiSpec []byte
 
 type BlockchainServer struct {
-	port uint16
+	port       uint16
+	grpcPort   uint16
+	network    *common.Network
+	wallet     *wallet.Wallet
+	blockchain *Blockchain
+
+	faucet       bool
+	faucetGrants map[string]time.Time
+	muxFaucet    sync.Mutex
+
+	webhooks *webhookStore
 }
 
-func NewBlockchainServer(port uint16) *BlockchainServer {
-	return &BlockchainServer{port}
+func NewBlockchainServer(port uint16, grpcPort uint16, network *common.Network, faucet bool, webhooksPath string) *BlockchainServer {
+	bcs := &BlockchainServer{port: port, grpcPort: grpcPort, network: network, faucet: faucet}
+	bcs.wallet = wallet.NewWallet(network)
+	bcs.blockchain = NewBlockchain(bcs.wallet.BlockchainAddress(), port, network)
+	bcs.faucetGrants = make(map[string]time.Time)
+	bcs.webhooks = newWebhookStore(webhooksPath)
+	return bcs
 }
 
 func (bcs *BlockchainServer) Port() uint16 {
@@ -31,13 +62,7 @@ func (bcs *BlockchainServer) PortStr() string {
 }
 
 func (bcs *BlockchainServer) GetBlockchain() *Blockchain {
-	bc, ok := cache["blockchain"]
-	if !ok {
-		minersWallet := wallet.NewWallet()
-		bc = NewBlockchain(minersWallet.BlockchainAddress(), bcs.Port())
-		cache["blockchain"] = bc
-	}
-	return bc
+	return bcs.blockchain
 }
 
 func (bcs *BlockchainServer) GetChain(res http.ResponseWriter, req *http.Request) {
@@ -47,6 +72,119 @@ func (bcs *BlockchainServer) GetChain(res http.ResponseWriter, req *http.Request
 		bc := bcs.GetBlockchain()
 		m, _ := bc.MarshalJSON()
 		io.WriteString(res, string(m[:]))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodGet)
+	}
+}
+
+// pageParams parses the from and limit query parameters of paginated
+// endpoints, capping limit at PAGE_MAX_LIMIT.
+func pageParams(req *http.Request) (int, int, error) {
+	from, limit := 0, PAGE_DEFAULT_LIMIT
+	var err error
+	if v := req.URL.Query().Get("from"); v != "" {
+		if from, err = strconv.Atoi(v); err != nil || from < 0 {
+			return 0, 0, errors.New("invalid from")
+		}
+	}
+	if v := req.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			return 0, 0, errors.New("invalid limit")
+		}
+	}
+	if limit > PAGE_MAX_LIMIT {
+		limit = PAGE_MAX_LIMIT
+	}
+	return from, limit, nil
+}
+
+// Blocks serves GET /blocks?from=&limit= pages of the chain.
+func (bcs *BlockchainServer) Blocks(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		res.Header().Add("Content-Type", "application/json")
+		from, limit, err := pageParams(req)
+		if err != nil {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, err.Error())
+			return
+		}
+
+		bc := bcs.GetBlockchain()
+		blocks := bc.Blocks(from, limit)
+		page := struct {
+			Blocks []*Block `json:"blocks"`
+			From   int      `json:"from"`
+			Limit  int      `json:"limit"`
+			Height int      `json:"height"`
+			Next   *int     `json:"next"`
+		}{
+			Blocks: blocks,
+			From:   from,
+			Limit:  limit,
+			Height: bc.Height(),
+		}
+		if next := from + len(blocks); len(blocks) > 0 && next <= bc.Height() {
+			page.Next = &next
+		}
+		m, _ := json.Marshal(page)
+		io.WriteString(res, string(m[:]))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodGet)
+	}
+}
+
+// Block serves GET /blocks/{height} and GET /blocks/hash/{hash}.
+func (bcs *BlockchainServer) Block(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		res.Header().Add("Content-Type", "application/json")
+		bc := bcs.GetBlockchain()
+		var b *Block
+		path := strings.TrimPrefix(req.URL.Path, "/blocks/")
+		if strings.HasPrefix(path, "hash/") {
+			h, err := hex.DecodeString(strings.TrimPrefix(path, "hash/"))
+			if err != nil || len(h) != 32 {
+				common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "invalid hash")
+				return
+			}
+			var hash [32]byte
+			copy(hash[:], h)
+			b = bc.BlockByHash(hash)
+		} else {
+			height, err := strconv.Atoi(path)
+			if err != nil || height < 0 {
+				common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "invalid height")
+				return
+			}
+			b = bc.BlockByHeight(height)
+		}
+		if b == nil {
+			common.WriteError(res, http.StatusNotFound, common.ERR_NOT_FOUND, "block not found")
+			return
+		}
+		m, _ := b.MarshalJSON()
+		io.WriteString(res, string(m[:]))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodGet)
+	}
+}
+
+func (bcs *BlockchainServer) Tip(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		res.Header().Add("Content-Type", "application/json")
+		bc := bcs.GetBlockchain()
+		last := bc.LastBlock()
+		m, _ := json.Marshal(struct {
+			Height int    `json:"height"`
+			Hash   string `json:"hash"`
+		}{
+			Height: last.Height(),
+			Hash:   fmt.Sprintf("%x", last.Hash()),
+		})
+		io.WriteString(res, string(m[:]))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodGet)
 	}
 }
 
@@ -58,6 +196,8 @@ func (bcs *BlockchainServer) TransactionPool(res http.ResponseWriter, req *http.
 		tp := bc.TransactionPool()
 		m, _ := json.Marshal(tp)
 		io.WriteString(res, string(m[:]))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodGet)
 	}
 }
 
@@ -74,42 +214,105 @@ func (bcs *BlockchainServer) Transactions(res http.ResponseWriter, req *http.Req
 	case http.MethodPost:
 		decoder := json.NewDecoder(req.Body)
 		var t common.Transaction
-		decoder.Decode(&t)
+		if err := decoder.Decode(&t); err != nil {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, err.Error())
+			return
+		}
 		bc := bcs.GetBlockchain()
-		isCreated := bc.CreateTransaction(&t)
-		res.Header().Add("Content-Type", "application/json")
-		var m []byte
-		if !isCreated {
-			res.WriteHeader(http.StatusBadRequest)
-			m = common.JsonStatus("fail")
-		} else {
-			res.WriteHeader(http.StatusCreated)
-			m = common.JsonStatus("success")
+		if err := bc.SubmitTransaction(&t); err != nil {
+			common.WriteError(res, http.StatusUnprocessableEntity, common.ERR_TRANSACTION_REJECTED, err.Error())
+			return
 		}
-		io.WriteString(res, string(m))
+		res.Header().Add("Content-Type", "application/json")
+		res.WriteHeader(http.StatusCreated)
+		io.WriteString(res, string(common.JsonTransactionID("success", common.TransactionID(&t))))
 
 	case http.MethodPut:
 		decoder := json.NewDecoder(req.Body)
 		var t common.Transaction
-		decoder.Decode(&t)
+		if err := decoder.Decode(&t); err != nil {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, err.Error())
+			return
+		}
 		bc := bcs.GetBlockchain()
-		isUpdated := bc.AddTransaction(&t)
-		res.Header().Add("Content-Type", "application/json")
-		var m []byte
-		if !isUpdated {
-			res.WriteHeader(http.StatusBadRequest)
-			m = common.JsonStatus("fail")
-		} else {
-			m = common.JsonStatus("success")
+		if err := bc.CheckTransaction(&t); err != nil {
+			common.WriteError(res, http.StatusUnprocessableEntity, common.ERR_TRANSACTION_REJECTED, err.Error())
+			return
 		}
-		io.WriteString(res, string(m))
+		bc.AddTransaction(&t)
+		res.Header().Add("Content-Type", "application/json")
+		io.WriteString(res, string(common.JsonStatus("success")))
 
-	case http.MethodDelete:
+	default:
+		common.MethodNotAllowed(res, req, http.MethodGet, http.MethodPost, http.MethodPut)
+	}
+
+}
+
+func (bcs *BlockchainServer) TransactionByID(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		id := strings.TrimPrefix(req.URL.Path, "/transactions/")
+		res.Header().Add("Content-Type", "application/json")
+		if id == "" {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing transaction id")
+			return
+		}
 		bc := bcs.GetBlockchain()
-		bc.ClearTransactionPool()
-		io.WriteString(res, string(common.JsonStatus("success")))
+		status := bc.TransactionStatus(id)
+		if status.Status == TX_STATUS_UNKNOWN {
+			common.WriteError(res, http.StatusNotFound, common.ERR_NOT_FOUND, "transaction not found")
+			return
+		}
+		m, _ := json.Marshal(status)
+		io.WriteString(res, string(m[:]))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodGet)
 	}
+}
 
+// AddressTransactions serves GET /addresses/{addr}/transactions?from=&limit=,
+// newest first.
+func (bcs *BlockchainServer) AddressTransactions(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		res.Header().Add("Content-Type", "application/json")
+		path := strings.TrimPrefix(req.URL.Path, "/addresses/")
+		address := strings.TrimSuffix(path, "/transactions")
+		if address == "" || address == path || strings.Contains(address, "/") {
+			common.WriteError(res, http.StatusNotFound, common.ERR_NOT_FOUND, "no such route "+req.URL.Path)
+			return
+		}
+		address, err := bcs.network.ParseAddress(address)
+		if err != nil {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, err.Error())
+			return
+		}
+		from, limit, err := pageParams(req)
+		if err != nil {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, err.Error())
+			return
+		}
+
+		bc := bcs.GetBlockchain()
+		page := struct {
+			Address      string                `json:"address"`
+			Transactions []*AddressTransaction `json:"transactions"`
+			From         int                   `json:"from"`
+			Limit        int                   `json:"limit"`
+			Total        int                   `json:"total"`
+		}{
+			Address:      address,
+			Transactions: bc.AddressTransactions(address, from, limit),
+			From:         from,
+			Limit:        limit,
+			Total:        bc.AddressTransactionCount(address),
+		}
+		m, _ := json.Marshal(page)
+		io.WriteString(res, string(m[:]))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodGet)
+	}
 }
 
 func (bcs *BlockchainServer) Amounts(res http.ResponseWriter, req *http.Request) {
@@ -117,7 +320,13 @@ func (bcs *BlockchainServer) Amounts(res http.ResponseWriter, req *http.Request)
 	case http.MethodGet:
 		address := req.URL.Query().Get("address")
 		if address == "" {
-			// say bye bye
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing address")
+			return
+		}
+		address, err := bcs.network.ParseAddress(address)
+		if err != nil {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, err.Error())
+			return
 		}
 
 		res.Header().Add("Content-Type", "application/json")
@@ -127,39 +336,160 @@ func (bcs *BlockchainServer) Amounts(res http.ResponseWriter, req *http.Request)
 		io.WriteString(res, fmt.Sprintf("%f", amount))
 
 	default:
-		res.WriteHeader(http.StatusBadRequest)
-		log.Println("ERROR: Invalid HTTP Method")
+		common.MethodNotAllowed(res, req, http.MethodGet)
+	}
+
+}
+
+// Nonces tells the nonce the next transaction of an address must carry,
+// counting its transactions waiting in the pool.
+func (bcs *BlockchainServer) Nonces(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		address := req.URL.Query().Get("address")
+		if address == "" {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing address")
+			return
+		}
+		address, err := bcs.network.ParseAddress(address)
+		if err != nil {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, err.Error())
+			return
+		}
+		res.Header().Add("Content-Type", "application/json")
+		io.WriteString(res, strconv.FormatUint(bcs.GetBlockchain().NextNonce(address), 10))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodGet)
+	}
+}
 
+func (bcs *BlockchainServer) Supply(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		res.Header().Add("Content-Type", "application/json")
+		bc := bcs.GetBlockchain()
+		m, _ := json.Marshal(bc.Supply())
+		io.WriteString(res, string(m[:]))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodGet)
 	}
+}
+
+// Faucet pays FAUCET_AMOUNT from the miner's wallet to the requested address,
+// at most once every FAUCET_COOLDOWN_MIN minutes per address.
+func (bcs *BlockchainServer) Faucet(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		res.Header().Add("Content-Type", "application/json")
+		if !bcs.faucet {
+			common.WriteError(res, http.StatusNotFound, common.ERR_NOT_FOUND, "faucet disabled")
+			return
+		}
+		decoder := json.NewDecoder(req.Body)
+		var fr common.FaucetRequest
+		if err := decoder.Decode(&fr); err != nil || !fr.Validate() {
+			log.Println("ERROR: missing field(s)")
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing address")
+			return
+		}
+		address, err := bcs.network.ParseAddress(*fr.Address)
+		if err != nil {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, err.Error())
+			return
+		}
+		fr.Address = &address
+
+		bcs.muxFaucet.Lock()
+		defer bcs.muxFaucet.Unlock()
+		if last, ok := bcs.faucetGrants[*fr.Address]; ok && time.Since(last) < time.Minute*FAUCET_COOLDOWN_MIN {
+			wait := time.Minute*FAUCET_COOLDOWN_MIN - time.Since(last)
+			res.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			common.WriteError(res, http.StatusTooManyRequests, common.ERR_RATE_LIMITED, "address was funded less than "+strconv.Itoa(FAUCET_COOLDOWN_MIN)+" minutes ago")
+			return
+		}
 
+		bc := bcs.GetBlockchain()
+		t := bcs.wallet.CreateTransaction(*fr.Address, FAUCET_AMOUNT, 0, bc.NextNonce(bcs.wallet.BlockchainAddress()))
+		if err := bcs.wallet.SignTransaction(t); err != nil {
+			log.Printf("ERROR: %v", err)
+			common.WriteError(res, http.StatusServiceUnavailable, common.ERR_UNAVAILABLE, "faucet cannot pay: "+err.Error())
+			return
+		}
+		if err := bc.SubmitTransaction(t); err != nil {
+			common.WriteError(res, http.StatusServiceUnavailable, common.ERR_UNAVAILABLE, "faucet cannot pay: "+err.Error())
+			return
+		}
+		bcs.faucetGrants[*fr.Address] = time.Now()
+		res.WriteHeader(http.StatusCreated)
+		io.WriteString(res, string(common.JsonTransactionID("success", common.TransactionID(t))))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
 }
 
+// Consensus switches to the longest valid chain of the neighbors, as one of
+// them asks once it mined a block. Transactions of the pool the new blocks
+// include are dropped, the others kept.
 func (bcs *BlockchainServer) Consensus(res http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodPut:
-		log.Printf("IGNORING ResolveConflicts")
-		// bc := bcs.GetBlockchain()
-		// replaced := bc.ResolveConflicts()
-
-		// res.Header().Add("Content-Type", "application/json")
-		// if replaced {
-		// 	io.WriteString(res, string(common.JsonStatus("success")))
-		// } else {
-		// 	io.WriteString(res, string(common.JsonStatus("fail")))
-		// }
+		bc := bcs.GetBlockchain()
+		replaced := bc.ResolveConflicts()
+
+		res.Header().Add("Content-Type", "application/json")
+		if replaced {
+			io.WriteString(res, string(common.JsonStatus("success")))
+		} else {
+			io.WriteString(res, string(common.JsonStatus("fail")))
+		}
 	default:
-		log.Printf("ERROR: Invalid HTTP Method")
-		res.WriteHeader(http.StatusBadRequest)
+		common.MethodNotAllowed(res, req, http.MethodPut)
+	}
+}
+
+// Handler routes the API under /v1, checked against openapi.yaml, and
+// unversioned for neighbors and older clients.
+func (bcs *BlockchainServer) Handler() http.Handler {
+	spec, err := common.NewAPISpec(openapiSpec)
+	if err != nil {
+		log.Fatalf("openapi.yaml: %v", err)
 	}
+
+	api := http.NewServeMux()
+	api.HandleFunc("/", common.NotFound)
+	api.HandleFunc("/openapi.json", spec.ServeSpec)             // GET
+	api.HandleFunc("/blockchain", bcs.GetChain)                 // GET
+	api.HandleFunc("/blocks", bcs.Blocks)                       // GET
+	api.HandleFunc("/blocks/", bcs.Block)                       // GET
+	api.HandleFunc("/tip", bcs.Tip)                             // GET
+	api.HandleFunc("/transactions", bcs.Transactions)           // GET POST PUT
+	api.HandleFunc("/transactions/", bcs.TransactionByID)       // GET
+	api.HandleFunc("/amounts", bcs.Amounts)                     // GET
+	api.HandleFunc("/nonces", bcs.Nonces)                       // GET
+	api.HandleFunc("/addresses/", bcs.AddressTransactions)      // GET
+	api.HandleFunc("/consensus", bcs.Consensus)                 // PUT
+	api.HandleFunc("/supply", bcs.Supply)                       // GET
+	api.HandleFunc("/faucet", bcs.Faucet)                       // POST
+	api.HandleFunc("/rpc", bcs.RPC)                             // POST
+	api.HandleFunc("/webhooks", bcs.Webhooks)                   // GET POST
+	api.HandleFunc("/webhooks/", bcs.Webhook)                   // GET DELETE
+	api.Handle("/ws", websocket.Server{Handler: bcs.WebSocket}) // GET (WebSocket)
+
+	mux := http.NewServeMux()
+	mux.Handle(common.API_PREFIX+"/", spec.Handler(api))
+	mux.Handle("/", api)
+	return mux
 }
 
 func (bcs *BlockchainServer) Run() {
-	http.HandleFunc("/blockchain", bcs.GetChain)       // GET
-	http.HandleFunc("/transactions", bcs.Transactions) // GET POST PUT DELETE
-	http.HandleFunc("/amounts", bcs.Amounts)           // GET
-	http.HandleFunc("/consensus", bcs.Consensus)       // PUT
+	handler := bcs.Handler()
 
-	log.Println("BlockchainServer listening on localhost:" + bcs.PortStr())
+	log.Printf("BlockchainServer (%s) listening on localhost:%s", bcs.network.Name, bcs.PortStr())
+	go bcs.RunGRPC(bcs.grpcPort)
+	if err := bcs.webhooks.load(); err != nil {
+		log.Fatal(err)
+	}
+	go bcs.webhooks.Run(bcs.GetBlockchain())
 	bcs.GetBlockchain().Run()
-	log.Fatal(http.ListenAndServe("localhost:"+bcs.PortStr(), nil))
+	log.Fatal(http.ListenAndServe("localhost:"+bcs.PortStr(), handler))
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"goblockchain/common"
+	"goblockchain/wallet"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func newTestServer(t *testing.T) *BlockchainServer {
+	t.Helper()
+	return NewBlockchainServer(0, 0, common.REGTEST, false, filepath.Join(t.TempDir(), "webhooks.json"))
+}
+
+func TestAddressTransactions(t *testing.T) {
+	bcs := newTestServer(t)
+	miner := bcs.wallet.BlockchainAddress()
+	bech32, _ := common.REGTEST.Bech32Address(miner)
+	tests := []struct {
+		name    string
+		address string
+		status  int
+	}{
+		{"base58", miner, http.StatusOK},
+		{"bech32", bech32, http.StatusOK},
+		{"invalid", "not-an-address", http.StatusBadRequest},
+		{"another network", wallet.NewWallet(common.MAINNET).BlockchainAddress(), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			bcs.AddressTransactions(rec, httptest.NewRequest(http.MethodGet, "/addresses/"+tt.address+"/transactions", nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var page struct{ Address string }
+			if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil || page.Address != miner {
+				t.Errorf("address of the page = %q, %v, want %s", page.Address, err, miner)
+			}
+		})
+	}
+}
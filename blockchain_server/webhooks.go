@@ -0,0 +1,636 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	. "goblockchain/blockchain"
+	"goblockchain/common"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	WEBHOOK_EVENT_PENDING   = "pending"
+	WEBHOOK_EVENT_CONFIRMED = "confirmed"
+	WEBHOOK_EVENT_REORGED   = "reorged"
+
+	DELIVERY_STATUS_PENDING   = "pending"
+	DELIVERY_STATUS_DELIVERED = "delivered"
+	DELIVERY_STATUS_FAILED    = "failed"
+
+	WEBHOOK_MAX_CONFIRMATIONS = 100
+	WEBHOOK_MAX_ATTEMPTS      = 8
+	WEBHOOK_RETRY_BASE_SEC    = 2
+	WEBHOOK_RETRY_MAX_SEC     = 600
+	WEBHOOK_TIMEOUT_SEC       = 10
+	WEBHOOK_HISTORY_SIZE      = 50
+
+	WEBHOOK_SIGNATURE_HEADER = "X-Webhook-Signature"
+	WEBHOOK_ID_HEADER        = "X-Webhook-ID"
+	WEBHOOK_DELIVERY_HEADER  = "X-Webhook-Delivery"
+
+	// WEBHOOK_AUTH_SCHEME prefixes the owner token in the Authorization
+	// header of the requests managing webhooks.
+	WEBHOOK_AUTH_SCHEME = "Bearer "
+)
+
+var (
+	ErrWebhookTarget = errors.New("url must not point to a loopback, private or link-local address")
+	ErrWebhookToken  = errors.New("missing or unknown webhook token")
+)
+
+// Webhook is a registration to be told about transactions that send funds
+// from or to Address. Pending transactions are reported as soon as they
+// enter the pool, and again once they are Confirmations blocks deep.
+//
+// Only the holder of the token returned on registration can see or delete
+// it. The token itself is never stored, Owner is its hash; registrations
+// saved before owners were recorded have none and are kept, but can only
+// be removed from the file.
+type Webhook struct {
+	ID            string             `json:"id"`
+	URL           string             `json:"url"`
+	Address       string             `json:"address"`
+	Confirmations int                `json:"confirmations"`
+	Secret        string             `json:"secret,omitempty"`
+	Token         string             `json:"token,omitempty"`
+	Owner         string             `json:"owner,omitempty"`
+	CreatedAt     int64              `json:"created_at"`
+	Deliveries    []*WebhookDelivery `json:"deliveries"`
+}
+
+// WebhookDelivery tracks one payload through its delivery attempts.
+type WebhookDelivery struct {
+	ID            string          `json:"id"`
+	Event         string          `json:"event"`
+	TransactionID string          `json:"transaction_id"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	ResponseCode  int             `json:"response_code,omitempty"`
+	LastError     string          `json:"last_error,omitempty"`
+	LastAttempt   int64           `json:"last_attempt,omitempty"`
+	NextAttempt   int64           `json:"next_attempt,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// WebhookPayload is the JSON body POSTed to a webhook. It is signed with
+// the webhook's secret as HMAC-SHA256 in the X-Webhook-Signature header.
+type WebhookPayload struct {
+	WebhookID     string  `json:"webhook_id"`
+	DeliveryID    string  `json:"delivery_id"`
+	Event         string  `json:"event"`
+	Address       string  `json:"address"`
+	TransactionID string  `json:"transaction_id"`
+	Direction     string  `json:"direction"`
+	Counterparty  string  `json:"counterparty"`
+	Value         float32 `json:"value"`
+	Fee           float32 `json:"fee"`
+	Height        int     `json:"height,omitempty"`
+	Confirmations int     `json:"confirmations"`
+	Timestamp     int64   `json:"timestamp"`
+}
+
+type WebhookRequest struct {
+	URL           *string `json:"url"`
+	Address       *string `json:"address"`
+	Confirmations *int    `json:"confirmations"`
+}
+
+// Validate checks wr and rewrites its address, which may be given in
+// Bech32, in Base58Check form. The host of the URL must resolve to public
+// addresses only: the node is not to be made to post to itself or to the
+// network behind it.
+func (wr *WebhookRequest) Validate(network *common.Network) error {
+	if wr.URL == nil || wr.Address == nil {
+		return errors.New("missing field(s)")
+	}
+	u, err := url.Parse(*wr.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("invalid url")
+	}
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+	for _, ip := range ips {
+		if !publicIP(ip) {
+			return fmt.Errorf("%w: %s", ErrWebhookTarget, ip)
+		}
+	}
+	address, err := network.ParseAddress(*wr.Address)
+	if err != nil {
+		return err
+	}
+	wr.Address = &address
+	if wr.Confirmations != nil && (*wr.Confirmations < 1 || *wr.Confirmations > WEBHOOK_MAX_CONFIRMATIONS) {
+		return fmt.Errorf("confirmations must be between 1 and %d", WEBHOOK_MAX_CONFIRMATIONS)
+	}
+	return nil
+}
+
+// publicIP reports whether webhooks may be delivered to ip: whether it is
+// none of the loopback, private, link-local, multicast and unspecified
+// addresses.
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// dialPublic refuses connections to addresses publicIP rejects, which a
+// host checked on registration can resolve to later, or a redirect lead
+// to.
+func dialPublic(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrWebhookTarget, host)
+	}
+	return nil
+}
+
+// tokenHash is the Owner of the webhooks registered with token.
+func tokenHash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// WebhookToken is the owner token the Authorization header of req carries,
+// empty if none.
+func WebhookToken(req *http.Request) string {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, WEBHOOK_AUTH_SCHEME) {
+		return ""
+	}
+	return strings.TrimPrefix(auth, WEBHOOK_AUTH_SCHEME)
+}
+
+// watchedTx is a confirmed transaction waiting for its webhook's
+// confirmation threshold.
+type watchedTx struct {
+	webhookID     string
+	transactionID string
+	height        int
+	transaction   *common.Transaction
+}
+
+// webhookStore keeps the registered webhooks, persisted as JSON in path so
+// they survive restarts, and delivers their payloads.
+type webhookStore struct {
+	path     string
+	webhooks map[string]*Webhook
+	watched  []*watchedTx
+	client   *http.Client
+	mux      sync.Mutex
+}
+
+func newWebhookStore(path string) *webhookStore {
+	dialer := &net.Dialer{Control: dialPublic}
+	return &webhookStore{
+		path:     path,
+		webhooks: make(map[string]*Webhook),
+		client: &http.Client{
+			Timeout:   time.Second * WEBHOOK_TIMEOUT_SEC,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+		},
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Panicf("ERROR: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// load reads the registrations saved in path and resumes the deliveries
+// that were still pending when the server stopped.
+func (ws *webhookStore) load() error {
+	data, err := os.ReadFile(ws.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var webhooks []*Webhook
+	if err := json.Unmarshal(data, &webhooks); err != nil {
+		return fmt.Errorf("%s: %v", ws.path, err)
+	}
+
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+	for _, w := range webhooks {
+		if w.Owner == "" {
+			log.Printf("WARNING: webhook %s has no owner, it can only be removed from %s", w.ID, ws.path)
+		}
+		ws.webhooks[w.ID] = w
+		for _, d := range w.Deliveries {
+			if d.Status == DELIVERY_STATUS_PENDING {
+				go ws.deliver(w, d)
+			}
+		}
+	}
+	log.Printf("action=webhooks, status=loaded, count=%d", len(webhooks))
+	return nil
+}
+
+// save writes the registrations to path. The caller must hold ws.mux.
+func (ws *webhookStore) save() {
+	webhooks := make([]*Webhook, 0, len(ws.webhooks))
+	for _, w := range ws.webhooks {
+		webhooks = append(webhooks, w)
+	}
+	data, _ := json.MarshalIndent(webhooks, "", "  ")
+	tmp := ws.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		log.Printf("ERROR: saving webhooks: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, ws.path); err != nil {
+		log.Printf("ERROR: saving webhooks: %v", err)
+	}
+}
+
+// owns reports whether token is the owner token of w. The caller must
+// hold ws.mux.
+func owns(w *Webhook, token string) bool {
+	return w.Owner != "" && subtle.ConstantTimeCompare([]byte(w.Owner), []byte(tokenHash(token))) == 1
+}
+
+// known reports whether token owns any webhook. The caller must hold
+// ws.mux.
+func (ws *webhookStore) known(token string) bool {
+	for _, w := range ws.webhooks {
+		if owns(w, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// Register adds a webhook owned by token, a new token if empty. The token
+// and the secret are only returned here.
+func (ws *webhookStore) Register(wr *WebhookRequest, token string) (*Webhook, error) {
+	w := &Webhook{
+		ID:            randomHex(16),
+		URL:           *wr.URL,
+		Address:       *wr.Address,
+		Confirmations: 1,
+		Secret:        randomHex(32),
+		CreatedAt:     time.Now().Unix(),
+		Deliveries:    []*WebhookDelivery{},
+	}
+	if wr.Confirmations != nil {
+		w.Confirmations = *wr.Confirmations
+	}
+
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+	if token == "" {
+		token = randomHex(32)
+	} else if !ws.known(token) {
+		return nil, ErrWebhookToken
+	}
+	w.Owner = tokenHash(token)
+	ws.webhooks[w.ID] = w
+	ws.save()
+	v := ws.view(w)
+	v.Secret = w.Secret
+	v.Token = token
+	return v, nil
+}
+
+// Delete removes the webhook id if token owns it.
+func (ws *webhookStore) Delete(id string, token string) bool {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+	if w, ok := ws.webhooks[id]; !ok || !owns(w, token) {
+		return false
+	}
+	delete(ws.webhooks, id)
+	watched := ws.watched[:0]
+	for _, wt := range ws.watched {
+		if wt.webhookID != id {
+			watched = append(watched, wt)
+		}
+	}
+	ws.watched = watched
+	ws.save()
+	return true
+}
+
+// view returns a copy of w without its secret and owner, safe to marshal
+// while deliveries keep updating the original.
+func (ws *webhookStore) view(w *Webhook) *Webhook {
+	v := *w
+	v.Secret = ""
+	v.Owner = ""
+	v.Deliveries = make([]*WebhookDelivery, len(w.Deliveries))
+	for i, d := range w.Deliveries {
+		c := *d
+		v.Deliveries[i] = &c
+	}
+	return &v
+}
+
+// Get returns the webhook id if token owns it.
+func (ws *webhookStore) Get(id string, token string) *Webhook {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+	w, ok := ws.webhooks[id]
+	if !ok || !owns(w, token) {
+		return nil
+	}
+	return ws.view(w)
+}
+
+// List returns the webhooks token owns.
+func (ws *webhookStore) List(token string) []*Webhook {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+	webhooks := make([]*Webhook, 0)
+	for _, w := range ws.webhooks {
+		if owns(w, token) {
+			webhooks = append(webhooks, ws.view(w))
+		}
+	}
+	return webhooks
+}
+
+// Run turns chain events into webhook deliveries until the server stops,
+// resubscribing if the event bus drops it for falling behind.
+func (ws *webhookStore) Run(bc *Blockchain) {
+	for {
+		events, _ := bc.Subscribe()
+		for e := range events {
+			ws.handle(e)
+		}
+		log.Println("ERROR: webhooks fell behind the event bus, resubscribing")
+	}
+}
+
+func (ws *webhookStore) handle(e *Event) {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+	switch e.Type {
+	case EVENT_TX_ADDED:
+		for _, w := range ws.webhooks {
+			if e.Involves(w.Address) {
+				ws.enqueue(w, WEBHOOK_EVENT_PENDING, common.TransactionID(e.Transaction), e.Transaction, 0, 0)
+			}
+		}
+	case EVENT_BLOCK_CONNECTED:
+		height := e.Block.Height()
+		for i, t := range e.Block.Transactions() {
+			id := BlockTransactionID(t, height, i)
+			for _, w := range ws.webhooks {
+				if t.Tx.SenderAddress != w.Address && t.Tx.RecipientAddress != w.Address {
+					continue
+				}
+				ws.watched = append(ws.watched, &watchedTx{webhookID: w.ID, transactionID: id, height: height, transaction: t})
+			}
+		}
+		watched := ws.watched[:0]
+		for _, wt := range ws.watched {
+			w := ws.webhooks[wt.webhookID]
+			confirmations := height - wt.height + 1
+			if confirmations < w.Confirmations {
+				watched = append(watched, wt)
+				continue
+			}
+			ws.enqueue(w, WEBHOOK_EVENT_CONFIRMED, wt.transactionID, wt.transaction, wt.height, confirmations)
+		}
+		ws.watched = watched
+	case EVENT_BLOCK_DISCONNECTED:
+		height := e.Block.Height()
+		watched := ws.watched[:0]
+		for _, wt := range ws.watched {
+			if wt.height < height {
+				watched = append(watched, wt)
+			}
+		}
+		ws.watched = watched
+		for i, t := range e.Block.Transactions() {
+			id := BlockTransactionID(t, height, i)
+			for _, w := range ws.webhooks {
+				if t.Tx.SenderAddress == w.Address || t.Tx.RecipientAddress == w.Address {
+					ws.enqueue(w, WEBHOOK_EVENT_REORGED, id, t, height, 0)
+				}
+			}
+		}
+	}
+}
+
+// enqueue records a delivery of event about t for w and starts sending it.
+// The caller must hold ws.mux.
+func (ws *webhookStore) enqueue(w *Webhook, event string, id string, t *common.Transaction, height int, confirmations int) {
+	direction, counterparty := Direction(t, w.Address)
+	p := &WebhookPayload{
+		WebhookID:     w.ID,
+		DeliveryID:    randomHex(16),
+		Event:         event,
+		Address:       w.Address,
+		TransactionID: id,
+		Direction:     direction,
+		Counterparty:  counterparty,
+		Value:         t.Tx.Value,
+		Fee:           t.Tx.Fee,
+		Height:        height,
+		Confirmations: confirmations,
+		Timestamp:     time.Now().Unix(),
+	}
+	payload, _ := json.Marshal(p)
+	d := &WebhookDelivery{
+		ID:            p.DeliveryID,
+		Event:         event,
+		TransactionID: id,
+		Status:        DELIVERY_STATUS_PENDING,
+		Payload:       payload,
+	}
+	w.Deliveries = append(w.Deliveries, d)
+	if n := len(w.Deliveries); n > WEBHOOK_HISTORY_SIZE {
+		w.Deliveries = w.Deliveries[n-WEBHOOK_HISTORY_SIZE:]
+	}
+	ws.save()
+	go ws.deliver(w, d)
+}
+
+// Sign returns the value of the X-Webhook-Signature header for payload.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff is the delay before retrying a delivery that failed attempts
+// times: WEBHOOK_RETRY_BASE_SEC doubled on each failure, capped at
+// WEBHOOK_RETRY_MAX_SEC.
+func backoff(attempts int) time.Duration {
+	d := time.Second * WEBHOOK_RETRY_BASE_SEC
+	for i := 1; i < attempts && d < time.Second*WEBHOOK_RETRY_MAX_SEC; i++ {
+		d *= 2
+	}
+	if d > time.Second*WEBHOOK_RETRY_MAX_SEC {
+		d = time.Second * WEBHOOK_RETRY_MAX_SEC
+	}
+	return d
+}
+
+// deliver POSTs d to w, retrying with exponential backoff until it is
+// acknowledged with a 2xx status or WEBHOOK_MAX_ATTEMPTS is reached.
+func (ws *webhookStore) deliver(w *Webhook, d *WebhookDelivery) {
+	ws.mux.Lock()
+	if _, ok := ws.webhooks[w.ID]; !ok || d.Status != DELIVERY_STATUS_PENDING {
+		ws.mux.Unlock()
+		return
+	}
+	if wait := time.Until(time.Unix(d.NextAttempt, 0)); d.NextAttempt > 0 && wait > 0 {
+		ws.mux.Unlock()
+		time.AfterFunc(wait, func() { ws.deliver(w, d) })
+		return
+	}
+	target, secret, payload := w.URL, w.Secret, d.Payload
+	ws.mux.Unlock()
+
+	code, err := ws.post(target, secret, w.ID, d.ID, payload)
+
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+	d.Attempts += 1
+	d.LastAttempt = time.Now().Unix()
+	d.ResponseCode = code
+	d.NextAttempt = 0
+	switch {
+	case err == nil:
+		d.Status = DELIVERY_STATUS_DELIVERED
+		d.LastError = ""
+	case d.Attempts >= WEBHOOK_MAX_ATTEMPTS:
+		d.Status = DELIVERY_STATUS_FAILED
+		d.LastError = err.Error()
+		log.Printf("ERROR: webhook %s delivery %s failed: %v", w.ID, d.ID, err)
+	default:
+		d.LastError = err.Error()
+		wait := backoff(d.Attempts)
+		d.NextAttempt = time.Now().Add(wait).Unix()
+		time.AfterFunc(wait, func() { ws.deliver(w, d) })
+	}
+	if _, ok := ws.webhooks[w.ID]; ok {
+		ws.save()
+	}
+}
+
+func (ws *webhookStore) post(target string, secret string, webhookID string, deliveryID string, payload []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WEBHOOK_SIGNATURE_HEADER, Sign(secret, payload))
+	req.Header.Set(WEBHOOK_ID_HEADER, webhookID)
+	req.Header.Set(WEBHOOK_DELIVERY_HEADER, deliveryID)
+	res, err := ws.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, errors.New("unexpected status " + strconv.Itoa(res.StatusCode))
+	}
+	return res.StatusCode, nil
+}
+
+// Webhooks serves GET /webhooks, listing the registrations of the owner
+// token and their delivery status, and POST /webhooks, registering a new
+// one. The secret used to sign payloads and the owner token, new unless
+// the request carries one already, are only returned on registration.
+func (bcs *BlockchainServer) Webhooks(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		res.Header().Add("Content-Type", "application/json")
+		token := WebhookToken(req)
+		if token == "" {
+			common.WriteError(res, http.StatusUnauthorized, common.ERR_UNAUTHORIZED, ErrWebhookToken.Error())
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Webhooks []*Webhook `json:"webhooks"`
+		}{
+			Webhooks: bcs.webhooks.List(token),
+		})
+		io.WriteString(res, string(m[:]))
+	case http.MethodPost:
+		res.Header().Add("Content-Type", "application/json")
+		decoder := json.NewDecoder(req.Body)
+		var wr WebhookRequest
+		if err := decoder.Decode(&wr); err != nil {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, err.Error())
+			return
+		}
+		if err := wr.Validate(bcs.network); err != nil {
+			log.Printf("ERROR: %v", err)
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, err.Error())
+			return
+		}
+		w, err := bcs.webhooks.Register(&wr, WebhookToken(req))
+		if err != nil {
+			common.WriteError(res, http.StatusUnauthorized, common.ERR_UNAUTHORIZED, err.Error())
+			return
+		}
+		res.WriteHeader(http.StatusCreated)
+		m, _ := json.Marshal(w)
+		io.WriteString(res, string(m[:]))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodGet, http.MethodPost)
+	}
+}
+
+// Webhook serves GET and DELETE /webhooks/{id} to the owner of the
+// webhook. Those of other owners are not found.
+func (bcs *BlockchainServer) Webhook(res http.ResponseWriter, req *http.Request) {
+	id := strings.TrimPrefix(req.URL.Path, "/webhooks/")
+	token := WebhookToken(req)
+	if token == "" {
+		common.WriteError(res, http.StatusUnauthorized, common.ERR_UNAUTHORIZED, ErrWebhookToken.Error())
+		return
+	}
+	switch req.Method {
+	case http.MethodGet:
+		res.Header().Add("Content-Type", "application/json")
+		w := bcs.webhooks.Get(id, token)
+		if w == nil {
+			common.WriteError(res, http.StatusNotFound, common.ERR_NOT_FOUND, "webhook not found")
+			return
+		}
+		m, _ := json.Marshal(w)
+		io.WriteString(res, string(m[:]))
+	case http.MethodDelete:
+		res.Header().Add("Content-Type", "application/json")
+		if !bcs.webhooks.Delete(id, token) {
+			common.WriteError(res, http.StatusNotFound, common.ERR_NOT_FOUND, "webhook not found")
+			return
+		}
+		io.WriteString(res, string(common.JsonStatus("success")))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodGet, http.MethodDelete)
+	}
+}
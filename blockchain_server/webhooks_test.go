@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"goblockchain/common"
+	"goblockchain/wallet"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWebhookTargets(t *testing.T) {
+	address := wallet.NewWallet(common.REGTEST).BlockchainAddress()
+	tests := []struct {
+		url string
+		err error
+	}{
+		{"http://93.184.216.34/hook", nil},
+		{"http://127.0.0.1/hook", ErrWebhookTarget},
+		{"http://localhost:8080/hook", ErrWebhookTarget},
+		{"http://10.0.0.1/hook", ErrWebhookTarget},
+		{"https://192.168.1.1/hook", ErrWebhookTarget},
+		{"http://169.254.169.254/latest/meta-data", ErrWebhookTarget},
+		{"http://[::1]/hook", ErrWebhookTarget},
+		{"http://[fd00::1]/hook", ErrWebhookTarget},
+		{"http://0.0.0.0/hook", ErrWebhookTarget},
+		{"http://224.0.0.1/hook", ErrWebhookTarget},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			wr := &WebhookRequest{URL: &tt.url, Address: &address}
+			if err := wr.Validate(common.REGTEST); !errors.Is(err, tt.err) {
+				t.Errorf("Validate() = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestWebhookDeliveryTarget(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		t.Error("the webhook was delivered to the loopback address")
+	}))
+	defer server.Close()
+	ws := newWebhookStore(filepath.Join(t.TempDir(), "webhooks.json"))
+	if _, err := ws.post(server.URL, "secret", "webhook", "delivery", []byte("{}")); !errors.Is(err, ErrWebhookTarget) {
+		t.Errorf("post() to %s = %v, want %v", server.URL, err, ErrWebhookTarget)
+	}
+}
+
+func TestWebhookOwners(t *testing.T) {
+	handler := newTestServer(t).Handler()
+	address := wallet.NewWallet(common.REGTEST).BlockchainAddress()
+	do := func(method string, path string, token string) *httptest.ResponseRecorder {
+		t.Helper()
+		body := ""
+		if method == http.MethodPost {
+			body = `{"url":"http://93.184.216.34/hook","address":"` + address + `"}`
+		}
+		req := httptest.NewRequest(method, common.API_PREFIX+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", WEBHOOK_AUTH_SCHEME+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	register := func(token string) *Webhook {
+		t.Helper()
+		rec := do(http.MethodPost, "/webhooks", token)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("registering: status = %d: %s", rec.Code, rec.Body)
+		}
+		var w Webhook
+		if err := json.Unmarshal(rec.Body.Bytes(), &w); err != nil {
+			t.Fatal(err)
+		}
+		if w.Token == "" || w.Secret == "" || w.Owner != "" {
+			t.Fatalf("registered webhook = %+v, want its token and secret only", w)
+		}
+		return &w
+	}
+	list := func(token string) []*Webhook {
+		t.Helper()
+		var page struct{ Webhooks []*Webhook }
+		rec := do(http.MethodGet, "/webhooks", token)
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); rec.Code != http.StatusOK || err != nil {
+			t.Fatalf("listing: status = %d, %v", rec.Code, err)
+		}
+		return page.Webhooks
+	}
+
+	alice := register("")
+	if second := register(alice.Token); second.Token != alice.Token {
+		t.Errorf("token of a second registration = %s, want %s", second.Token, alice.Token)
+	}
+	bob := register("")
+	if bob.Token == alice.Token {
+		t.Fatal("two owners share a token")
+	}
+	if got := len(list(alice.Token)); got != 2 {
+		t.Errorf("alice lists %d webhooks, want 2", got)
+	}
+	for _, w := range list(bob.Token) {
+		if w.ID != bob.ID || w.Secret != "" || w.Token != "" {
+			t.Errorf("bob lists %+v", w)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"register with an unknown token", http.MethodPost, "/webhooks", "unknown", http.StatusUnauthorized},
+		{"list without a token", http.MethodGet, "/webhooks", "", http.StatusUnauthorized},
+		{"get without a token", http.MethodGet, "/webhooks/" + alice.ID, "", http.StatusUnauthorized},
+		{"get of another owner", http.MethodGet, "/webhooks/" + alice.ID, bob.Token, http.StatusNotFound},
+		{"delete without a token", http.MethodDelete, "/webhooks/" + alice.ID, "", http.StatusUnauthorized},
+		{"delete of another owner", http.MethodDelete, "/webhooks/" + alice.ID, bob.Token, http.StatusNotFound},
+		{"get", http.MethodGet, "/webhooks/" + alice.ID, alice.Token, http.StatusOK},
+		{"delete", http.MethodDelete, "/webhooks/" + alice.ID, alice.Token, http.StatusOK},
+		{"get deleted", http.MethodGet, "/webhooks/" + alice.ID, alice.Token, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := do(tt.method, tt.path, tt.token); rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, rec.Code, tt.status, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	. "goblockchain/blockchain"
+	"goblockchain/common"
+	"golang.org/x/net/websocket"
+	"log"
+	"sync"
+)
+
+const (
+	WS_TOPIC_BLOCKS       = "blocks"
+	WS_TOPIC_TRANSACTIONS = "transactions"
+)
+
+// wsRequest is sent by clients to choose what they receive. An empty
+// address list means every address.
+type wsRequest struct {
+	Action    string   `json:"action"`
+	Topics    []string `json:"topics"`
+	Addresses []string `json:"addresses"`
+}
+
+type wsMessage struct {
+	Topic       string              `json:"topic,omitempty"`
+	Type        string              `json:"type"`
+	ID          string              `json:"id,omitempty"`
+	Block       *Block              `json:"block,omitempty"`
+	Transaction *common.Transaction `json:"transaction,omitempty"`
+	Topics      []string            `json:"topics,omitempty"`
+	Addresses   []string            `json:"addresses,omitempty"`
+	Error       string              `json:"error,omitempty"`
+}
+
+type wsFilter struct {
+	topics    map[string]bool
+	addresses map[string]bool
+	mux       sync.Mutex
+}
+
+func (f *wsFilter) set(req *wsRequest) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.topics = make(map[string]bool)
+	for _, t := range req.Topics {
+		f.topics[t] = true
+	}
+	f.addresses = make(map[string]bool)
+	for _, a := range req.Addresses {
+		f.addresses[a] = true
+	}
+}
+
+func (f *wsFilter) match(topic string, e *Event) bool {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if !f.topics[topic] {
+		return false
+	}
+	if len(f.addresses) == 0 {
+		return true
+	}
+	for a := range f.addresses {
+		if e.Involves(a) {
+			return true
+		}
+	}
+	return false
+}
+
+func eventMessage(e *Event) *wsMessage {
+	switch e.Type {
+	case EVENT_BLOCK_CONNECTED, EVENT_BLOCK_DISCONNECTED:
+		return &wsMessage{Topic: WS_TOPIC_BLOCKS, Type: e.Type, Block: e.Block}
+	case EVENT_TX_ADDED, EVENT_TX_REMOVED:
+		return &wsMessage{Topic: WS_TOPIC_TRANSACTIONS, Type: e.Type, ID: common.TransactionID(e.Transaction), Transaction: e.Transaction}
+	}
+	return nil
+}
+
+// WebSocket streams chain events to a client. The client sends
+// {"action":"subscribe","topics":[...],"addresses":[...]} at any time to
+// replace its subscription.
+func (bcs *BlockchainServer) WebSocket(ws *websocket.Conn) {
+	defer ws.Close()
+	events, unsubscribe := bcs.GetBlockchain().Subscribe()
+	defer unsubscribe()
+
+	filter := new(wsFilter)
+	var muxSend sync.Mutex
+	send := func(m *wsMessage) error {
+		muxSend.Lock()
+		defer muxSend.Unlock()
+		return websocket.JSON.Send(ws, m)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			var req wsRequest
+			if err := websocket.JSON.Receive(ws, &req); err != nil {
+				return
+			}
+			if req.Action != "subscribe" {
+				send(&wsMessage{Type: "error", Error: "unknown action"})
+				continue
+			}
+			// balances and transactions know addresses in Base58 form only
+			for i, a := range req.Addresses {
+				if address, err := bcs.network.ParseAddress(a); err == nil {
+					req.Addresses[i] = address
+				}
+			}
+			filter.set(&req)
+			send(&wsMessage{Type: "subscribed", Topics: req.Topics, Addresses: req.Addresses})
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		case e, ok := <-events:
+			if !ok {
+				send(&wsMessage{Type: "error", Error: "subscriber fell behind"})
+				return
+			}
+			m := eventMessage(e)
+			if m == nil || !filter.match(m.Topic, e) {
+				continue
+			}
+			if err := send(m); err != nil {
+				log.Printf("ERROR: websocket: %v", err)
+				return
+			}
+		}
+	}
+}
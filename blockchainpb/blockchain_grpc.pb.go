@@ -0,0 +1,468 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: blockchain.proto
+
+package blockchainpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Blockchain_GetChainInfo_FullMethodName      = "/goblockchain.v1.Blockchain/GetChainInfo"
+	Blockchain_GetBlock_FullMethodName          = "/goblockchain.v1.Blockchain/GetBlock"
+	Blockchain_GetBlocks_FullMethodName         = "/goblockchain.v1.Blockchain/GetBlocks"
+	Blockchain_GetTransaction_FullMethodName    = "/goblockchain.v1.Blockchain/GetTransaction"
+	Blockchain_GetMempool_FullMethodName        = "/goblockchain.v1.Blockchain/GetMempool"
+	Blockchain_GetBalance_FullMethodName        = "/goblockchain.v1.Blockchain/GetBalance"
+	Blockchain_SubmitTransaction_FullMethodName = "/goblockchain.v1.Blockchain/SubmitTransaction"
+	Blockchain_SubscribeBlocks_FullMethodName   = "/goblockchain.v1.Blockchain/SubscribeBlocks"
+	Blockchain_SubscribeMempool_FullMethodName  = "/goblockchain.v1.Blockchain/SubscribeMempool"
+)
+
+// BlockchainClient is the client API for Blockchain service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BlockchainClient interface {
+	GetChainInfo(ctx context.Context, in *GetChainInfoRequest, opts ...grpc.CallOption) (*ChainInfo, error)
+	GetBlock(ctx context.Context, in *GetBlockRequest, opts ...grpc.CallOption) (*Block, error)
+	GetBlocks(ctx context.Context, in *GetBlocksRequest, opts ...grpc.CallOption) (*GetBlocksResponse, error)
+	GetTransaction(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*TransactionStatus, error)
+	GetMempool(ctx context.Context, in *GetMempoolRequest, opts ...grpc.CallOption) (*GetMempoolResponse, error)
+	GetBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*Balance, error)
+	SubmitTransaction(ctx context.Context, in *SubmitTransactionRequest, opts ...grpc.CallOption) (*SubmitTransactionResponse, error)
+	// SubscribeBlocks streams every block connected to or disconnected from
+	// the chain. A reorg shows up as DISCONNECTED events for the abandoned
+	// blocks, newest first, followed by CONNECTED events for the new ones.
+	SubscribeBlocks(ctx context.Context, in *SubscribeBlocksRequest, opts ...grpc.CallOption) (Blockchain_SubscribeBlocksClient, error)
+	// SubscribeMempool streams every transaction admitted to the pool.
+	SubscribeMempool(ctx context.Context, in *SubscribeMempoolRequest, opts ...grpc.CallOption) (Blockchain_SubscribeMempoolClient, error)
+}
+
+type blockchainClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBlockchainClient(cc grpc.ClientConnInterface) BlockchainClient {
+	return &blockchainClient{cc}
+}
+
+func (c *blockchainClient) GetChainInfo(ctx context.Context, in *GetChainInfoRequest, opts ...grpc.CallOption) (*ChainInfo, error) {
+	out := new(ChainInfo)
+	err := c.cc.Invoke(ctx, Blockchain_GetChainInfo_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blockchainClient) GetBlock(ctx context.Context, in *GetBlockRequest, opts ...grpc.CallOption) (*Block, error) {
+	out := new(Block)
+	err := c.cc.Invoke(ctx, Blockchain_GetBlock_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blockchainClient) GetBlocks(ctx context.Context, in *GetBlocksRequest, opts ...grpc.CallOption) (*GetBlocksResponse, error) {
+	out := new(GetBlocksResponse)
+	err := c.cc.Invoke(ctx, Blockchain_GetBlocks_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blockchainClient) GetTransaction(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*TransactionStatus, error) {
+	out := new(TransactionStatus)
+	err := c.cc.Invoke(ctx, Blockchain_GetTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blockchainClient) GetMempool(ctx context.Context, in *GetMempoolRequest, opts ...grpc.CallOption) (*GetMempoolResponse, error) {
+	out := new(GetMempoolResponse)
+	err := c.cc.Invoke(ctx, Blockchain_GetMempool_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blockchainClient) GetBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*Balance, error) {
+	out := new(Balance)
+	err := c.cc.Invoke(ctx, Blockchain_GetBalance_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blockchainClient) SubmitTransaction(ctx context.Context, in *SubmitTransactionRequest, opts ...grpc.CallOption) (*SubmitTransactionResponse, error) {
+	out := new(SubmitTransactionResponse)
+	err := c.cc.Invoke(ctx, Blockchain_SubmitTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blockchainClient) SubscribeBlocks(ctx context.Context, in *SubscribeBlocksRequest, opts ...grpc.CallOption) (Blockchain_SubscribeBlocksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Blockchain_ServiceDesc.Streams[0], Blockchain_SubscribeBlocks_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &blockchainSubscribeBlocksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Blockchain_SubscribeBlocksClient interface {
+	Recv() (*BlockEvent, error)
+	grpc.ClientStream
+}
+
+type blockchainSubscribeBlocksClient struct {
+	grpc.ClientStream
+}
+
+func (x *blockchainSubscribeBlocksClient) Recv() (*BlockEvent, error) {
+	m := new(BlockEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *blockchainClient) SubscribeMempool(ctx context.Context, in *SubscribeMempoolRequest, opts ...grpc.CallOption) (Blockchain_SubscribeMempoolClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Blockchain_ServiceDesc.Streams[1], Blockchain_SubscribeMempool_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &blockchainSubscribeMempoolClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Blockchain_SubscribeMempoolClient interface {
+	Recv() (*Transaction, error)
+	grpc.ClientStream
+}
+
+type blockchainSubscribeMempoolClient struct {
+	grpc.ClientStream
+}
+
+func (x *blockchainSubscribeMempoolClient) Recv() (*Transaction, error) {
+	m := new(Transaction)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// BlockchainServer is the server API for Blockchain service.
+// All implementations must embed UnimplementedBlockchainServer
+// for forward compatibility
+type BlockchainServer interface {
+	GetChainInfo(context.Context, *GetChainInfoRequest) (*ChainInfo, error)
+	GetBlock(context.Context, *GetBlockRequest) (*Block, error)
+	GetBlocks(context.Context, *GetBlocksRequest) (*GetBlocksResponse, error)
+	GetTransaction(context.Context, *GetTransactionRequest) (*TransactionStatus, error)
+	GetMempool(context.Context, *GetMempoolRequest) (*GetMempoolResponse, error)
+	GetBalance(context.Context, *GetBalanceRequest) (*Balance, error)
+	SubmitTransaction(context.Context, *SubmitTransactionRequest) (*SubmitTransactionResponse, error)
+	// SubscribeBlocks streams every block connected to or disconnected from
+	// the chain. A reorg shows up as DISCONNECTED events for the abandoned
+	// blocks, newest first, followed by CONNECTED events for the new ones.
+	SubscribeBlocks(*SubscribeBlocksRequest, Blockchain_SubscribeBlocksServer) error
+	// SubscribeMempool streams every transaction admitted to the pool.
+	SubscribeMempool(*SubscribeMempoolRequest, Blockchain_SubscribeMempoolServer) error
+	mustEmbedUnimplementedBlockchainServer()
+}
+
+// UnimplementedBlockchainServer must be embedded to have forward compatible implementations.
+type UnimplementedBlockchainServer struct {
+}
+
+func (UnimplementedBlockchainServer) GetChainInfo(context.Context, *GetChainInfoRequest) (*ChainInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetChainInfo not implemented")
+}
+func (UnimplementedBlockchainServer) GetBlock(context.Context, *GetBlockRequest) (*Block, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlock not implemented")
+}
+func (UnimplementedBlockchainServer) GetBlocks(context.Context, *GetBlocksRequest) (*GetBlocksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlocks not implemented")
+}
+func (UnimplementedBlockchainServer) GetTransaction(context.Context, *GetTransactionRequest) (*TransactionStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransaction not implemented")
+}
+func (UnimplementedBlockchainServer) GetMempool(context.Context, *GetMempoolRequest) (*GetMempoolResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMempool not implemented")
+}
+func (UnimplementedBlockchainServer) GetBalance(context.Context, *GetBalanceRequest) (*Balance, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBalance not implemented")
+}
+func (UnimplementedBlockchainServer) SubmitTransaction(context.Context, *SubmitTransactionRequest) (*SubmitTransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitTransaction not implemented")
+}
+func (UnimplementedBlockchainServer) SubscribeBlocks(*SubscribeBlocksRequest, Blockchain_SubscribeBlocksServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeBlocks not implemented")
+}
+func (UnimplementedBlockchainServer) SubscribeMempool(*SubscribeMempoolRequest, Blockchain_SubscribeMempoolServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeMempool not implemented")
+}
+func (UnimplementedBlockchainServer) mustEmbedUnimplementedBlockchainServer() {}
+
+// UnsafeBlockchainServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BlockchainServer will
+// result in compilation errors.
+type UnsafeBlockchainServer interface {
+	mustEmbedUnimplementedBlockchainServer()
+}
+
+func RegisterBlockchainServer(s grpc.ServiceRegistrar, srv BlockchainServer) {
+	s.RegisterService(&Blockchain_ServiceDesc, srv)
+}
+
+func _Blockchain_GetChainInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetChainInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlockchainServer).GetChainInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Blockchain_GetChainInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlockchainServer).GetChainInfo(ctx, req.(*GetChainInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Blockchain_GetBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlockchainServer).GetBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Blockchain_GetBlock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlockchainServer).GetBlock(ctx, req.(*GetBlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Blockchain_GetBlocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBlocksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlockchainServer).GetBlocks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Blockchain_GetBlocks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlockchainServer).GetBlocks(ctx, req.(*GetBlocksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Blockchain_GetTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlockchainServer).GetTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Blockchain_GetTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlockchainServer).GetTransaction(ctx, req.(*GetTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Blockchain_GetMempool_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMempoolRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlockchainServer).GetMempool(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Blockchain_GetMempool_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlockchainServer).GetMempool(ctx, req.(*GetMempoolRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Blockchain_GetBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlockchainServer).GetBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Blockchain_GetBalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlockchainServer).GetBalance(ctx, req.(*GetBalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Blockchain_SubmitTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlockchainServer).SubmitTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Blockchain_SubmitTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlockchainServer).SubmitTransaction(ctx, req.(*SubmitTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Blockchain_SubscribeBlocks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeBlocksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BlockchainServer).SubscribeBlocks(m, &blockchainSubscribeBlocksServer{stream})
+}
+
+type Blockchain_SubscribeBlocksServer interface {
+	Send(*BlockEvent) error
+	grpc.ServerStream
+}
+
+type blockchainSubscribeBlocksServer struct {
+	grpc.ServerStream
+}
+
+func (x *blockchainSubscribeBlocksServer) Send(m *BlockEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Blockchain_SubscribeMempool_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeMempoolRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BlockchainServer).SubscribeMempool(m, &blockchainSubscribeMempoolServer{stream})
+}
+
+type Blockchain_SubscribeMempoolServer interface {
+	Send(*Transaction) error
+	grpc.ServerStream
+}
+
+type blockchainSubscribeMempoolServer struct {
+	grpc.ServerStream
+}
+
+func (x *blockchainSubscribeMempoolServer) Send(m *Transaction) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Blockchain_ServiceDesc is the grpc.ServiceDesc for Blockchain service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Blockchain_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "goblockchain.v1.Blockchain",
+	HandlerType: (*BlockchainServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetChainInfo",
+			Handler:    _Blockchain_GetChainInfo_Handler,
+		},
+		{
+			MethodName: "GetBlock",
+			Handler:    _Blockchain_GetBlock_Handler,
+		},
+		{
+			MethodName: "GetBlocks",
+			Handler:    _Blockchain_GetBlocks_Handler,
+		},
+		{
+			MethodName: "GetTransaction",
+			Handler:    _Blockchain_GetTransaction_Handler,
+		},
+		{
+			MethodName: "GetMempool",
+			Handler:    _Blockchain_GetMempool_Handler,
+		},
+		{
+			MethodName: "GetBalance",
+			Handler:    _Blockchain_GetBalance_Handler,
+		},
+		{
+			MethodName: "SubmitTransaction",
+			Handler:    _Blockchain_SubmitTransaction_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeBlocks",
+			Handler:       _Blockchain_SubscribeBlocks_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SubscribeMempool",
+			Handler:       _Blockchain_SubscribeMempool_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "blockchain.proto",
+}
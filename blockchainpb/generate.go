@@ -0,0 +1,5 @@
+// Package blockchainpb holds the gRPC service definition of the blockchain
+// node and the code generated from it.
+package blockchainpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative blockchain.proto
@@ -0,0 +1,122 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/btcsuite/btcutil/base58"
+	"golang.org/x/crypto/ripemd160"
+)
+
+const (
+	// ADDRESS_LENGTH is the decoded length of a Base58Check address:
+	// version byte, RIPEMD-160 hash and checksum.
+	ADDRESS_LENGTH = 25
+	// ADDRESS_KEY_VERSION is the first symbol of the data of a Bech32
+	// address, telling the kind of hash that follows.
+	ADDRESS_KEY_VERSION = 0
+	// ADDRESS_MULTISIG_VERSION is the first symbol of the data of the
+	// Bech32 address of a multisig policy.
+	ADDRESS_MULTISIG_VERSION = 1
+)
+
+var (
+	ErrAddressEncoding = errors.New("address is neither Base58 nor Bech32")
+	ErrAddressLength   = errors.New("address has the wrong length")
+	ErrAddressChecksum = errors.New("address checksum does not match, check it for typos")
+	ErrAddressVersion  = errors.New("address belongs to another network")
+	ErrAddressBech32   = errors.New("address is in Bech32 form where Base58Check is expected")
+)
+
+// AddressFromPublicKey derives the Base58Check blockchain address of a
+// public key for the network identified by version. A malformed key has
+// none: its address is empty.
+func AddressFromPublicKey(publicKey *PublicKey, version byte) string {
+	key, err := publicKey.addressBytes()
+	if err != nil {
+		return ""
+	}
+	// 2. Perform SHA-256 hashing on the public key (32 bytes).
+	h2 := sha256.New()
+	h2.Write(key)
+	digest2 := h2.Sum(nil)
+	// 3. Perform RIPEMD-160 hashing on the result of SHA-256 (20 bytes).
+	h3 := ripemd160.New()
+	h3.Write(digest2)
+	digest3 := h3.Sum(nil)
+	// 4. Add the network version byte in front of RIPEMD-160 hash (0x00 for Main Network).
+	vd4 := make([]byte, 21)
+	vd4[0] = version
+	copy(vd4[1:], digest3[:])
+	// 5. Perform SHA-256 hash on the extended RIPEMD-160 result.
+	h5 := sha256.New()
+	h5.Write(vd4)
+	digest5 := h5.Sum(nil)
+	// 6. Perform SHA-256 hash on the result of the previous SHA-256 hash.
+	h6 := sha256.New()
+	h6.Write(digest5)
+	digest6 := h6.Sum(nil)
+	// 7. Take the first 4 bytes of the second SHA-256 hash for checksum.
+	chsum := digest6[:4]
+	// 8. Add the 4 checksum bytes from 7 at the end of extended RIPEMD-160 hash from 4 (25 bytes).
+	dc8 := make([]byte, 25)
+	copy(dc8[:21], vd4[:])
+	copy(dc8[21:], chsum[:])
+	// 9. Convert the result from a byte string into base58.
+	return base58.Encode(dc8)
+}
+
+// ValidateAddress checks that address is the Base58Check encoding of a
+// RIPEMD-160 hash, as built by AddressFromPublicKey, for the network
+// identified by version. Transactions carry addresses in this form only,
+// see Network.ParseAddress.
+func ValidateAddress(address string, version byte) error {
+	if isBech32Address(address) {
+		return fmt.Errorf("%w: %q", ErrAddressBech32, address)
+	}
+	b := base58.Decode(address)
+	if len(b) == 0 {
+		return fmt.Errorf("%w: %q", ErrAddressEncoding, address)
+	}
+	if len(b) != ADDRESS_LENGTH {
+		return fmt.Errorf("%w: %q", ErrAddressLength, address)
+	}
+	h := sha256.Sum256(b[:21])
+	h = sha256.Sum256(h[:])
+	if !bytes.Equal(h[:4], b[21:]) {
+		return fmt.Errorf("%w: %q", ErrAddressChecksum, address)
+	}
+	if b[0] != version {
+		return fmt.Errorf("%w: %q", ErrAddressVersion, address)
+	}
+	return nil
+}
+
+// IsAddressOfPublicKey reports whether address was derived from publicKey,
+// whatever network version byte it carries.
+func IsAddressOfPublicKey(address string, publicKey *PublicKey) bool {
+	if publicKey == nil || len(publicKey.Key) == 0 {
+		return false
+	}
+	version, err := AddressVersion(address)
+	if err != nil {
+		return false
+	}
+	return AddressFromPublicKey(publicKey, version) == address
+}
+
+// IsAddressOfMultisig reports whether address is the multisig address of
+// ms, whatever network version byte it carries.
+func IsAddressOfMultisig(address string, ms *Multisig) bool {
+	version, err := AddressVersion(address)
+	if err != nil {
+		return false
+	}
+	for _, n := range NETWORKS {
+		if n.MultisigVersion == version {
+			return ms.Address(n) == address
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package common
+
+import (
+	"errors"
+	"github.com/btcsuite/btcutil/base58"
+	"testing"
+)
+
+func TestValidateAddress(t *testing.T) {
+	publicKey := generatePublicKey(t, P256)
+	address := AddressFromPublicKey(publicKey, REGTEST.AddressVersion)
+	bech32, err := REGTEST.Bech32Address(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := base58.Decode(address)
+	// a hash byte changed without updating the checksum
+	corrupted := append([]byte{}, decoded...)
+	corrupted[10] ^= 0xff
+
+	tests := []struct {
+		name    string
+		address string
+		err     error
+	}{
+		{"valid", address, nil},
+		{"empty", "", ErrAddressEncoding},
+		{"not Base58", "0OIl", ErrAddressEncoding},
+		{"short", base58.Encode(decoded[:ADDRESS_LENGTH-1]), ErrAddressLength},
+		{"long", base58.Encode(append(decoded, 0)), ErrAddressLength},
+		{"checksum", base58.Encode(corrupted), ErrAddressChecksum},
+		{"another network", AddressFromPublicKey(publicKey, MAINNET.AddressVersion), ErrAddressVersion},
+		{"Bech32", bech32, ErrAddressBech32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAddress(tt.address, REGTEST.AddressVersion)
+			if tt.err == nil {
+				if err != nil {
+					t.Errorf("ValidateAddress(%q) = %v", tt.address, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("ValidateAddress(%q) = %v, want %v", tt.address, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNetworkValidateAddress(t *testing.T) {
+	publicKey := generatePublicKey(t, SECP256K1)
+	ms, err := NewMultisig(1, []*PublicKey{publicKey, generatePublicKey(t, ED25519)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range NETWORKS {
+		t.Run(n.Name, func(t *testing.T) {
+			address := AddressFromPublicKey(publicKey, n.AddressVersion)
+			if err := n.ValidateAddress(address); err != nil || !n.IsAddressOf(address) || n.IsMultisigAddress(address) {
+				t.Errorf("key address %s: ValidateAddress() = %v, IsMultisigAddress() = %t", address, err, n.IsMultisigAddress(address))
+			}
+			multisig := ms.Address(n)
+			if err := n.ValidateAddress(multisig); err != nil || !n.IsAddressOf(multisig) || !n.IsMultisigAddress(multisig) {
+				t.Errorf("multisig address %s: ValidateAddress() = %v, IsMultisigAddress() = %t", multisig, err, n.IsMultisigAddress(multisig))
+			}
+			if !IsAddressOfPublicKey(address, publicKey) || !IsAddressOfMultisig(multisig, ms) {
+				t.Errorf("%s and %s are not of their key and policy", address, multisig)
+			}
+			for _, other := range NETWORKS {
+				if other == n {
+					continue
+				}
+				if err := other.ValidateAddress(address); !errors.Is(err, ErrAddressVersion) {
+					t.Errorf("%s.ValidateAddress() of a %s address = %v, want %v", other.Name, n.Name, err, ErrAddressVersion)
+				}
+				if other.IsAddressOf(multisig) || other.IsMultisigAddress(multisig) {
+					t.Errorf("%s multisig address %s is one of %s", n.Name, multisig, other.Name)
+				}
+			}
+		})
+	}
+	if IsAddressOfPublicKey(AddressFromPublicKey(generatePublicKey(t, SECP256K1), REGTEST.AddressVersion), publicKey) {
+		t.Error("the address of another key is of publicKey")
+	}
+}
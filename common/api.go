@@ -0,0 +1,171 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	API_PREFIX = "/v1"
+
+	// API_UNVALIDATED_BODY marks operations whose handler reports malformed
+	// bodies in its own protocol, such as JSON-RPC.
+	API_UNVALIDATED_BODY = "x-unvalidated-body"
+)
+
+// Error codes of the structured error bodies returned by the HTTP APIs.
+const (
+	ERR_INVALID_REQUEST      = "invalid_request"
+	ERR_NOT_FOUND            = "not_found"
+	ERR_METHOD_NOT_ALLOWED   = "method_not_allowed"
+	ERR_TRANSACTION_REJECTED = "transaction_rejected"
+	ERR_RATE_LIMITED         = "rate_limited"
+	ERR_WALLET_LOCKED        = "wallet_locked"
+	ERR_WRONG_PASSPHRASE     = "wrong_passphrase"
+	ERR_UNAUTHORIZED         = "unauthorized"
+	ERR_FORBIDDEN            = "forbidden"
+	ERR_CONFLICT             = "conflict"
+	ERR_UNAVAILABLE          = "unavailable"
+	ERR_GATEWAY              = "gateway_error"
+	ERR_INTERNAL             = "internal_error"
+)
+
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func JsonError(code string, message string) []byte {
+	m, _ := json.Marshal(struct {
+		Error APIError `json:"error"`
+	}{
+		Error: APIError{Code: code, Message: message},
+	})
+	return m
+}
+
+// WriteError replies to the request with status and an error body.
+func WriteError(res http.ResponseWriter, status int, code string, message string) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	io.WriteString(res, string(JsonError(code, message)))
+}
+
+// MethodNotAllowed replies 405, listing the methods the route supports.
+func MethodNotAllowed(res http.ResponseWriter, req *http.Request, allowed ...string) {
+	log.Println("ERROR: Invalid HTTP Method")
+	res.Header().Set("Allow", strings.Join(allowed, ", "))
+	WriteError(res, http.StatusMethodNotAllowed, ERR_METHOD_NOT_ALLOWED,
+		fmt.Sprintf("method %s not allowed, use %s", req.Method, strings.Join(allowed, ", ")))
+}
+
+func NotFound(res http.ResponseWriter, req *http.Request) {
+	WriteError(res, http.StatusNotFound, ERR_NOT_FOUND, "no such route "+req.URL.Path)
+}
+
+// APISpec is the OpenAPI document of a server's versioned API. Requests
+// under API_PREFIX are checked against it before reaching the handlers, so
+// the document and the behaviour cannot drift apart.
+type APISpec struct {
+	doc    *openapi3.T
+	router routers.Router
+}
+
+func NewAPISpec(data []byte) (*APISpec, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &APISpec{doc: doc, router: router}, nil
+}
+
+// Handler validates requests against the spec and passes them to next
+// with API_PREFIX stripped, so the same handlers serve the legacy routes.
+func (s *APISpec) Handler(next http.Handler) http.Handler {
+	next = http.StripPrefix(API_PREFIX, next)
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		route, params, err := s.router.FindRoute(req)
+		if err != nil && err.Error() == routers.ErrMethodNotAllowed.Error() {
+			// the handler answers 405 along with the methods it allows
+			next.ServeHTTP(res, req)
+			return
+		}
+		if err != nil {
+			NotFound(res, req)
+			return
+		}
+		_, unvalidated := route.Operation.Extensions[API_UNVALIDATED_BODY]
+		input := &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: params,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				ExcludeRequestBody: unvalidated,
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			},
+		}
+		if err := openapi3filter.ValidateRequest(req.Context(), input); err != nil {
+			message := validationMessage(err)
+			log.Printf("ERROR: %s %s: %s", req.Method, req.URL.Path, message)
+			WriteError(res, http.StatusBadRequest, ERR_INVALID_REQUEST, message)
+			return
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+// validationMessage shortens a spec validation error to the parameter or
+// body field at fault and the reason.
+func validationMessage(err error) string {
+	var re *openapi3filter.RequestError
+	if !errors.As(err, &re) {
+		return err.Error()
+	}
+	reason := re.Reason
+	var se *openapi3.SchemaError
+	if errors.As(re.Err, &se) {
+		reason = se.Reason
+		if reason == "" {
+			reason = "value does not satisfy " + se.SchemaField
+		}
+		if path := se.JSONPointer(); len(path) > 0 && !strings.Contains(reason, `"`+path[len(path)-1]+`"`) {
+			reason = strings.Join(path, ".") + ": " + reason
+		}
+	} else if reason == "" && re.Err != nil {
+		reason = re.Err.Error()
+	}
+	if re.Parameter != nil {
+		return fmt.Sprintf("%s parameter %q: %s", re.Parameter.In, re.Parameter.Name, reason)
+	}
+	return "request body: " + reason
+}
+
+// ServeSpec serves the OpenAPI document as JSON.
+func (s *APISpec) ServeSpec(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		res.Header().Add("Content-Type", "application/json")
+		m, _ := json.Marshal(s.doc)
+		io.WriteString(res, string(m[:]))
+	default:
+		MethodNotAllowed(res, req, http.MethodGet)
+	}
+}
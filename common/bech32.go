@@ -0,0 +1,156 @@
+package common
+
+import (
+	"errors"
+	"strings"
+)
+
+// Bech32 (BIP-173) and Bech32m (BIP-350) encodings, which differ only in
+// the constant their checksum ends with.
+type Bech32Encoding int
+
+const (
+	BECH32 Bech32Encoding = iota
+	BECH32M
+
+	BECH32_CHARSET   = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	BECH32_CONST     = 1
+	BECH32M_CONST    = 0x2bc830a3
+	BECH32_MAX_LEN   = 90
+	BECH32_CHECKSUM  = 6
+	BECH32_SEPARATOR = '1'
+)
+
+var (
+	ErrBech32         = errors.New("invalid bech32 string")
+	ErrBech32Checksum = errors.New("bech32 checksum does not match")
+)
+
+func (e Bech32Encoding) constant() uint32 {
+	if e == BECH32M {
+		return BECH32M_CONST
+	}
+	return BECH32_CONST
+}
+
+func (e Bech32Encoding) String() string {
+	if e == BECH32M {
+		return "bech32m"
+	}
+	return "bech32"
+}
+
+func bech32Polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	values := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, hrp[i]>>5)
+	}
+	values = append(values, 0)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, hrp[i]&31)
+	}
+	return values
+}
+
+// EncodeBech32 encodes data, 5-bit values, under the human-readable part
+// hrp.
+func EncodeBech32(hrp string, data []byte, encoding Bech32Encoding) (string, error) {
+	hrp = strings.ToLower(hrp)
+	if len(hrp)+1+len(data)+BECH32_CHECKSUM > BECH32_MAX_LEN {
+		return "", ErrBech32
+	}
+	values := append(bech32HRPExpand(hrp), data...)
+	polymod := bech32Polymod(append(values, make([]byte, BECH32_CHECKSUM)...)) ^ encoding.constant()
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte(BECH32_SEPARATOR)
+	for _, v := range data {
+		if v > 31 {
+			return "", ErrBech32
+		}
+		sb.WriteByte(BECH32_CHARSET[v])
+	}
+	for i := 0; i < BECH32_CHECKSUM; i++ {
+		sb.WriteByte(BECH32_CHARSET[(polymod>>uint(5*(5-i)))&31])
+	}
+	return sb.String(), nil
+}
+
+// DecodeBech32 decodes a Bech32 or Bech32m string, all lower or all upper
+// case, into its human-readable part, in lower case, and its 5-bit data.
+func DecodeBech32(s string) (string, []byte, Bech32Encoding, error) {
+	if len(s) > BECH32_MAX_LEN || (strings.ToLower(s) != s && strings.ToUpper(s) != s) {
+		return "", nil, BECH32, ErrBech32
+	}
+	s = strings.ToLower(s)
+	pos := strings.LastIndexByte(s, BECH32_SEPARATOR)
+	if pos < 1 || pos+1+BECH32_CHECKSUM > len(s) {
+		return "", nil, BECH32, ErrBech32
+	}
+	hrp := s[:pos]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, BECH32, ErrBech32
+		}
+	}
+	data := make([]byte, 0, len(s)-pos-1)
+	for i := pos + 1; i < len(s); i++ {
+		v := strings.IndexByte(BECH32_CHARSET, s[i])
+		if v < 0 {
+			return "", nil, BECH32, ErrBech32
+		}
+		data = append(data, byte(v))
+	}
+	var encoding Bech32Encoding
+	switch bech32Polymod(append(bech32HRPExpand(hrp), data...)) {
+	case BECH32_CONST:
+		encoding = BECH32
+	case BECH32M_CONST:
+		encoding = BECH32M
+	default:
+		return "", nil, BECH32, ErrBech32Checksum
+	}
+	return hrp, data[:len(data)-BECH32_CHECKSUM], encoding, nil
+}
+
+// ConvertBits regroups data from groups of from bits into groups of to
+// bits, padding the last group with zeros if pad is set.
+func ConvertBits(data []byte, from uint, to uint, pad bool) ([]byte, error) {
+	var acc, bits uint
+	maxv := uint(1)<<to - 1
+	out := make([]byte, 0, len(data)*int(from)/int(to)+1)
+	for _, v := range data {
+		if uint(v)>>from != 0 {
+			return nil, ErrBech32
+		}
+		acc = acc<<from | uint(v)
+		bits += from
+		for bits >= to {
+			bits -= to
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(to-bits)&maxv))
+		}
+	} else if bits >= from || acc<<(to-bits)&maxv != 0 {
+		return nil, ErrBech32
+	}
+	return out, nil
+}
@@ -0,0 +1,144 @@
+package common
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBech32Valid(t *testing.T) {
+	tests := []struct {
+		s        string
+		encoding Bech32Encoding
+	}{
+		// BIP-173
+		{"A12UEL5L", BECH32},
+		{"a12uel5l", BECH32},
+		{"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs", BECH32},
+		{"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw", BECH32},
+		{"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j", BECH32},
+		{"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w", BECH32},
+		{"?1ezyfcl", BECH32},
+		// BIP-350
+		{"A1LQFN3A", BECH32M},
+		{"a1lqfn3a", BECH32M},
+		{"an83characterlonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11sg7hg6", BECH32M},
+		{"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx", BECH32M},
+		{"11llllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllludsr8", BECH32M},
+		{"split1checkupstagehandshakeupstreamerranterredcaperredlc445v", BECH32M},
+		{"?1v759aa", BECH32M},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			hrp, data, encoding, err := DecodeBech32(tt.s)
+			if err != nil {
+				t.Fatalf("DecodeBech32() = %v", err)
+			}
+			if encoding != tt.encoding {
+				t.Errorf("DecodeBech32() encoding = %s, want %s", encoding, tt.encoding)
+			}
+			s, err := EncodeBech32(hrp, data, encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s != strings.ToLower(tt.s) {
+				t.Errorf("EncodeBech32() = %s, want %s", s, strings.ToLower(tt.s))
+			}
+		})
+	}
+}
+
+func TestDecodeBech32Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+	}{
+		// BIP-173
+		{"HRP character 0x20", "\x201nwldj5"},
+		{"HRP character 0x7f", "\x7f1axkwrx"},
+		{"HRP character 0x80", "\x801eym55h"},
+		{"too long", "an84characterslonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1569pvx"},
+		{"no separator", "pzry9x0s0muk"},
+		{"empty HRP", "1pzry9x0s0muk"},
+		{"data character", "x1b4n0q5v"},
+		{"checksum too short", "li1dgmt3"},
+		{"checksum character", "de1lg7wt\xff"},
+		{"checksum of the upper case HRP", "A1G7SGD8"},
+		{"empty HRP, data", "10a06t8"},
+		{"empty HRP, checksum", "1qzzfhee"},
+		// BIP-350
+		{"bech32m HRP character 0x20", "\x201xj0phk"},
+		{"bech32m HRP character 0x7f", "\x7f1g6xzxy"},
+		{"bech32m HRP character 0x80", "\x801vctc34"},
+		{"bech32m too long", "an84characterslonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11d6pts4"},
+		{"bech32m no separator", "qyrz8wqd2c9m"},
+		{"bech32m empty HRP", "1qyrz8wqd2c9m"},
+		{"bech32m data character", "y1b0jsk6g"},
+		{"bech32m data character i", "lt1igcx5c0"},
+		{"bech32m checksum too short", "in1muywd"},
+		{"bech32m checksum character i", "mm1crxm3i"},
+		{"bech32m checksum character o", "au1s5cgom"},
+		{"bech32m checksum of the upper case HRP", "M1VUXWEZ"},
+		{"bech32m empty HRP, data", "16plkw9"},
+		{"bech32m empty HRP, checksum", "1p2gdwpf"},
+		// ours
+		{"mixed case", "A12uEL5L"},
+		{"mixed case bech32m", "abcdef1L7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := DecodeBech32(tt.s)
+			if !errors.Is(err, ErrBech32) && !errors.Is(err, ErrBech32Checksum) {
+				t.Errorf("DecodeBech32(%q) = %v, want an error", tt.s, err)
+			}
+		})
+	}
+}
+
+func TestParseBech32Address(t *testing.T) {
+	key := AddressFromPublicKey(generatePublicKey(t, SECP256K1), REGTEST.AddressVersion)
+	ms, err := NewMultisig(1, []*PublicKey{generatePublicKey(t, ED25519)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, address := range []string{key, ms.Address(REGTEST)} {
+		bech32, err := REGTEST.Bech32Address(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(bech32, REGTEST.Bech32HRP+"1") {
+			t.Errorf("Bech32Address() = %s, want the prefix %s1", bech32, REGTEST.Bech32HRP)
+		}
+		for _, s := range []string{bech32, strings.ToUpper(bech32)} {
+			if got, err := REGTEST.ParseAddress(s); err != nil || got != address {
+				t.Errorf("ParseAddress(%s) = %s, %v, want %s", s, got, err, address)
+			}
+		}
+	}
+
+	bech32, _ := REGTEST.Bech32Address(key)
+	mixed := strings.ToUpper(REGTEST.Bech32HRP) + bech32[len(REGTEST.Bech32HRP):]
+	testnet, _ := TESTNET.Bech32Address(AddressFromPublicKey(generatePublicKey(t, P256), TESTNET.AddressVersion))
+	corrupted := []byte(bech32)
+	if corrupted[len(corrupted)-1] == 'q' {
+		corrupted[len(corrupted)-1] = 'p'
+	} else {
+		corrupted[len(corrupted)-1] = 'q'
+	}
+	tests := []struct {
+		name    string
+		address string
+		err     error
+	}{
+		{"mixed case", mixed, ErrAddressEncoding},
+		{"HRP of another network", testnet, ErrAddressVersion},
+		{"checksum", string(corrupted), ErrAddressChecksum},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := REGTEST.ParseAddress(tt.address); !errors.Is(err, tt.err) {
+				t.Errorf("ParseAddress(%s) = %v, want %v", tt.address, err, tt.err)
+			}
+		})
+	}
+}
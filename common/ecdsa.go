@@ -1,48 +0,0 @@
-package common
-
-import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"encoding/hex"
-	"fmt"
-	"math/big"
-)
-
-type Signature struct {
-	R *big.Int
-	S *big.Int
-}
-
-func (s *Signature) String() string {
-	return fmt.Sprintf("%064x%064x", s.R, s.S)
-}
-
-func String2BigIntTuple(s string) (big.Int, big.Int) {
-	bx, _ := hex.DecodeString(s[:64])
-	by, _ := hex.DecodeString(s[64:])
-
-	var bix big.Int
-	var biy big.Int
-
-	_ = bix.SetBytes(bx)
-	_ = biy.SetBytes(by)
-
-	return bix, biy
-}
-
-func SignatureFromString(s string) *Signature {
-	x, y := String2BigIntTuple(s)
-	return &Signature{&x, &y}
-}
-
-func PublicKeyFromString(s string) *ecdsa.PublicKey {
-	x, y := String2BigIntTuple(s)
-	return &ecdsa.PublicKey{elliptic.P256(), &x, &y}
-}
-
-func PrivateKeyFromString(s string, publicKey *ecdsa.PublicKey) *ecdsa.PrivateKey {
-	b, _ := hex.DecodeString(s[:])
-	var bi big.Int
-	_ = bi.SetBytes(b)
-	return &ecdsa.PrivateKey{*publicKey, &bi}
-}
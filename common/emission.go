@@ -0,0 +1,47 @@
+package common
+
+import "math"
+
+type EmissionSchedule struct {
+	InitialReward   float32
+	HalvingInterval int
+	MaxSupply       float32
+}
+
+// Halvings returns how many times the reward has been halved at height.
+func (e EmissionSchedule) Halvings(height int) int {
+	if e.HalvingInterval <= 0 {
+		return 0
+	}
+	return height / e.HalvingInterval
+}
+
+// NextHalvingHeight returns the first height after height at which the
+// reward is halved again.
+func (e EmissionSchedule) NextHalvingHeight(height int) int {
+	if e.HalvingInterval <= 0 {
+		return 0
+	}
+	return (e.Halvings(height) + 1) * e.HalvingInterval
+}
+
+// Reward returns the newly minted coins a block at height may claim, given
+// the amount already issued by the blocks before it. The last reward is cut
+// short so the issued total never exceeds MaxSupply.
+func (e EmissionSchedule) Reward(height int, issued float32) float32 {
+	if height <= 0 {
+		return 0
+	}
+	halvings := e.Halvings(height)
+	if halvings >= 64 {
+		return 0
+	}
+	reward := float32(float64(e.InitialReward) / math.Pow(2, float64(halvings)))
+	if e.MaxSupply > 0 && issued+reward > e.MaxSupply {
+		reward = e.MaxSupply - issued
+	}
+	if reward < 0 {
+		return 0
+	}
+	return reward
+}
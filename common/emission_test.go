@@ -0,0 +1,91 @@
+package common
+
+import "testing"
+
+func TestHalvings(t *testing.T) {
+	e := EmissionSchedule{InitialReward: 50, HalvingInterval: 10, MaxSupply: 1000}
+	tests := []struct {
+		height   int
+		halvings int
+		next     int
+	}{
+		{0, 0, 10},
+		{1, 0, 10},
+		{9, 0, 10},
+		{10, 1, 20},
+		{19, 1, 20},
+		{20, 2, 30},
+		{639, 63, 640},
+		{640, 64, 650},
+	}
+	for _, tt := range tests {
+		if got := e.Halvings(tt.height); got != tt.halvings {
+			t.Errorf("Halvings(%d) = %d, want %d", tt.height, got, tt.halvings)
+		}
+		if got := e.NextHalvingHeight(tt.height); got != tt.next {
+			t.Errorf("NextHalvingHeight(%d) = %d, want %d", tt.height, got, tt.next)
+		}
+	}
+
+	never := EmissionSchedule{InitialReward: 50}
+	if got := never.Halvings(1000); got != 0 {
+		t.Errorf("Halvings() without an interval = %d, want 0", got)
+	}
+	if got := never.NextHalvingHeight(1000); got != 0 {
+		t.Errorf("NextHalvingHeight() without an interval = %d, want 0", got)
+	}
+}
+
+func TestReward(t *testing.T) {
+	capped := EmissionSchedule{InitialReward: 50, HalvingInterval: 10, MaxSupply: 1000}
+	uncapped := EmissionSchedule{InitialReward: 50, HalvingInterval: 10}
+	tests := []struct {
+		name     string
+		schedule EmissionSchedule
+		height   int
+		issued   float32
+		reward   float32
+	}{
+		{"genesis", capped, 0, 0, 0},
+		{"first block", capped, 1, 0, 50},
+		{"last before the halving", capped, 9, 400, 50},
+		{"first halving", capped, 10, 450, 25},
+		{"second halving", capped, 20, 700, 12.5},
+		{"up to the cap", capped, 5, 950, 50},
+		{"cut short by the cap", capped, 5, 990, 10},
+		{"at the cap", capped, 5, 1000, 0},
+		{"past the cap", capped, 5, 1001, 0},
+		{"63 halvings", capped, 639, 0, 50.0 / (1 << 63)},
+		{"64 halvings", capped, 640, 0, 0},
+		{"no cap", uncapped, 1, 1e9, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.Reward(tt.height, tt.issued); got != tt.reward {
+				t.Errorf("Reward(%d, %f) = %g, want %g", tt.height, tt.issued, got, tt.reward)
+			}
+		})
+	}
+}
+
+func TestEmissionCap(t *testing.T) {
+	for _, n := range NETWORKS {
+		t.Run(n.Name, func(t *testing.T) {
+			e := n.Emission
+			var issued float32
+			for height := 1; height <= 64*e.HalvingInterval; height++ {
+				reward := e.Reward(height, issued)
+				if reward < 0 {
+					t.Fatalf("Reward(%d) = %g", height, reward)
+				}
+				issued += reward
+				if issued > e.MaxSupply {
+					t.Fatalf("%g issued at height %d, over the cap of %g", issued, height, e.MaxSupply)
+				}
+			}
+			if reward := e.Reward(64*e.HalvingInterval, issued); reward != 0 {
+				t.Errorf("reward after 64 halvings = %g, want 0", reward)
+			}
+		})
+	}
+}
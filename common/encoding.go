@@ -0,0 +1,215 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// ENCODING_VERSION prefixes every canonical encoding so the format can
+// evolve without ambiguity between old and new hashes.
+const ENCODING_VERSION = 0x01
+
+// ENCODING_VERSION_NONCE prefixes the encoding of transactions that carry
+// a nonce.
+const ENCODING_VERSION_NONCE = 0x02
+
+// The canonical encoding is used for every hash and signature; JSON is only
+// used by the HTTP API. All integers are big-endian, floats are their
+// IEEE-754 bits, strings are prefixed with their uvarint length and
+// absent keys or signatures are encoded as a zero length.
+
+func writeBytes(buf *bytes.Buffer, b []byte) {
+	var l [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(l[:], uint64(len(b)))
+	buf.Write(l[:n])
+	buf.Write(b)
+}
+
+func writeFloat32(buf *bytes.Buffer, f float32) {
+	binary.Write(buf, binary.BigEndian, math.Float32bits(f))
+}
+
+func padded(b []byte) []byte {
+	p := make([]byte, 32)
+	copy(p[32-len(b):], b)
+	return p
+}
+
+// EncodeTransaction encodes the signed content of a transaction:
+// version | sender | recipient | value | fee | nonce. The first transaction
+// of a sender, of nonce 0, has no nonce and is version 1, as transactions
+// were before nonces: their hashes and signatures are unchanged.
+func EncodeTransaction(t *BlockTransaction) []byte {
+	buf := new(bytes.Buffer)
+	if t.Nonce == 0 {
+		buf.WriteByte(ENCODING_VERSION)
+	} else {
+		buf.WriteByte(ENCODING_VERSION_NONCE)
+	}
+	writeBytes(buf, []byte(t.SenderAddress))
+	writeBytes(buf, []byte(t.RecipientAddress))
+	writeFloat32(buf, t.Value)
+	writeFloat32(buf, t.Fee)
+	if t.Nonce != 0 {
+		binary.Write(buf, binary.BigEndian, t.Nonce)
+	}
+	return buf.Bytes()
+}
+
+// EncodePublicKey encodes a public key as its scheme's tag followed by the
+// key, except P-256 keys which are X | Y alone, as they were before
+// schemes: 64 bytes long, they are told apart from every tagged key.
+func EncodePublicKey(publicKey *PublicKey) []byte {
+	if publicKey == nil || len(publicKey.Key) == 0 {
+		return nil
+	}
+	if publicKey.Scheme.Tag() == SCHEME_TAG_P256 {
+		return publicKey.Key
+	}
+	return append([]byte{publicKey.Scheme.Tag()}, publicKey.Key...)
+}
+
+// DecodePublicKey is the inverse of EncodePublicKey; empty input decodes to
+// a nil key.
+func DecodePublicKey(b []byte) (*PublicKey, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	if len(b) == 64 {
+		return P256.ParsePublicKey(b)
+	}
+	scheme, err := SchemeByTag(b[0])
+	if err != nil || scheme == P256 {
+		return nil, errors.New("public key has an unknown scheme tag")
+	}
+	return scheme.ParsePublicKey(b[1:])
+}
+
+// EncodeSignature encodes a signature as R | S, each padded to 32 bytes.
+func EncodeSignature(s *Signature) []byte {
+	if s == nil || s.R == nil || s.S == nil {
+		return nil
+	}
+	return append(padded(s.R.Bytes()), padded(s.S.Bytes())...)
+}
+
+// DecodeSignature is the inverse of EncodeSignature; empty input decodes to
+// a nil signature.
+func DecodeSignature(b []byte) (*Signature, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	if len(b) != 64 {
+		return nil, errors.New("signature must be 64 bytes")
+	}
+	return &Signature{R: new(big.Int).SetBytes(b[:32]), S: new(big.Int).SetBytes(b[32:])}, nil
+}
+
+// EncodeMultisig encodes a multisig policy as M | N | keys, each key
+// encoded as by EncodePublicKey, with its length. Multisig addresses are
+// the hash of this encoding.
+func EncodeMultisig(ms *Multisig) []byte {
+	if ms == nil {
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteByte(byte(ms.M))
+	buf.WriteByte(byte(len(ms.Keys)))
+	for _, k := range ms.Keys {
+		writeBytes(buf, EncodePublicKey(k))
+	}
+	return buf.Bytes()
+}
+
+// DecodeMultisig is the inverse of EncodeMultisig; empty input decodes to
+// a nil policy.
+func DecodeMultisig(b []byte) (*Multisig, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	if len(b) < 2 {
+		return nil, fmt.Errorf("%w: truncated", ErrMultisig)
+	}
+	ms := &Multisig{M: int(b[0]), Keys: make([]*PublicKey, b[1])}
+	r := bytes.NewReader(b[2:])
+	for i := range ms.Keys {
+		l, err := binary.ReadUvarint(r)
+		if err != nil || l > uint64(r.Len()) {
+			return nil, fmt.Errorf("%w: truncated", ErrMultisig)
+		}
+		key := make([]byte, l)
+		r.Read(key)
+		if ms.Keys[i], err = DecodePublicKey(key); err != nil {
+			return nil, fmt.Errorf("%w: key %d: %v", ErrMultisig, i, err)
+		}
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%w: trailing bytes", ErrMultisig)
+	}
+	if err := ms.Check(); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+// EncodeSignedTransaction encodes a transaction as stored in a block:
+// transaction | public key | signature. The scheme of the signature is
+// the one of the public key. A multisig transaction, with neither, is
+// followed by its policy and the signature of each of its keys, absent
+// ones included.
+func EncodeSignedTransaction(t *Transaction) []byte {
+	buf := new(bytes.Buffer)
+	buf.Write(EncodeTransaction(&t.Tx))
+	writeBytes(buf, EncodePublicKey(t.SenderPublicKey))
+	writeBytes(buf, EncodeSignature(t.Signature))
+	if t.Multisig != nil {
+		writeBytes(buf, EncodeMultisig(t.Multisig))
+		for _, s := range t.Signatures {
+			writeBytes(buf, EncodeSignature(s))
+		}
+	}
+	return buf.Bytes()
+}
+
+// TransactionSigningHash is the digest signed by the sender.
+func TransactionSigningHash(t *BlockTransaction) [32]byte {
+	return sha256.Sum256(EncodeTransaction(t))
+}
+
+// TransactionID identifies a transaction by the hash of its canonical
+// encoding, public key and signature included.
+func TransactionID(t *Transaction) string {
+	sum := sha256.Sum256(EncodeSignedTransaction(t))
+	return hex.EncodeToString(sum[:])
+}
+
+// TransactionsHash commits to the ordered list of transactions of a block.
+func TransactionsHash(transactions []*Transaction) [32]byte {
+	h := sha256.New()
+	h.Write([]byte{ENCODING_VERSION})
+	for _, t := range transactions {
+		th := sha256.Sum256(EncodeSignedTransaction(t))
+		h.Write(th[:])
+	}
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// EncodeBlockHeader encodes the hashed fields of a block:
+// version | timestamp | nonce | previous hash | transactions hash.
+func EncodeBlockHeader(timestamp int64, nonce int, previousHash [32]byte, transactionsHash [32]byte) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(ENCODING_VERSION)
+	binary.Write(buf, binary.BigEndian, timestamp)
+	binary.Write(buf, binary.BigEndian, int64(nonce))
+	buf.Write(previousHash[:])
+	buf.Write(transactionsHash[:])
+	return buf.Bytes()
+}
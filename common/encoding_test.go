@@ -0,0 +1,143 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// The vector keys are the generators of the ECDSA curves and the key of
+// the first test of RFC 8032.
+const (
+	VECTOR_KEY_P256      = "6b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c2964fe342e2fe1a7f9b8ee7eb4a7c0f9e162bce33576b315ececbb6406837bf51f5"
+	VECTOR_KEY_SECP256K1 = "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"
+	VECTOR_KEY_ED25519   = "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a"
+)
+
+func vectorTransaction(scheme SignatureScheme, key string) *Transaction {
+	k, _ := hex.DecodeString(key)
+	return &Transaction{
+		SenderPublicKey: &PublicKey{Scheme: scheme, Key: k},
+		Signature:       &Signature{R: big.NewInt(1), S: big.NewInt(2)},
+		Tx: BlockTransaction{
+			SenderAddress:    "1BHw6xjWDXDpdc8TMHVHv9qJ7vRrpnyLi3",
+			RecipientAddress: "1KFHE7w8BhaENAswwryaoccDb6qcT6DbYY",
+			Value:            1.5,
+			Fee:              0.25,
+		},
+	}
+}
+
+// vectorNonceTransaction is the vector transaction as the eighth of its
+// sender.
+func vectorNonceTransaction() *Transaction {
+	t := vectorTransaction(P256, VECTOR_KEY_P256)
+	t.Tx.Nonce = 7
+	return t
+}
+
+// vectorMultisigTransaction is sent from the 2-of-3 policy of the vector
+// keys, signed with the first and the last in the order of the policy.
+func vectorMultisigTransaction() *Transaction {
+	t := vectorTransaction(P256, VECTOR_KEY_P256)
+	t.Multisig, _ = NewMultisig(2, []*PublicKey{
+		t.SenderPublicKey,
+		vectorTransaction(SECP256K1, VECTOR_KEY_SECP256K1).SenderPublicKey,
+		vectorTransaction(ED25519, VECTOR_KEY_ED25519).SenderPublicKey,
+	})
+	t.Signatures = []*Signature{t.Signature, nil, {R: big.NewInt(3), S: big.NewInt(4)}}
+	t.SenderPublicKey, t.Signature = nil, nil
+	return t
+}
+
+// encodingVectors pin the canonical encoding of fixed inputs. A node whose
+// encoding drifts from them would compute hashes and signatures no other
+// node agrees with.
+var encodingVectors = []struct {
+	name   string
+	encode func() []byte
+	hex    string
+	sha256 string
+}{
+	{
+		name:   "transaction",
+		encode: func() []byte { return EncodeTransaction(&vectorTransaction(P256, VECTOR_KEY_P256).Tx) },
+		hex:    "01223142487736786a5744584470646338544d4856487639714a37765272706e794c693322314b464845377738426861454e417377777279616f636344623671635436446259593fc000003e800000",
+		sha256: "87bea87a5ccef5cd819cb9d55372681bf49879887966edbc2df9453904485022",
+	},
+	{
+		name:   "signed transaction",
+		encode: func() []byte { return EncodeSignedTransaction(vectorTransaction(P256, VECTOR_KEY_P256)) },
+		hex:    "01223142487736786a5744584470646338544d4856487639714a37765272706e794c693322314b464845377738426861454e417377777279616f636344623671635436446259593fc000003e800000406b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c2964fe342e2fe1a7f9b8ee7eb4a7c0f9e162bce33576b315ececbb6406837bf51f54000000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002",
+		sha256: "9a443c4b7ecf3261d280e2ec0fddefa3724cb065f6d84be285fa3382e57ddf5d",
+	},
+	{
+		name:   "secp256k1 signed transaction",
+		encode: func() []byte { return EncodeSignedTransaction(vectorTransaction(SECP256K1, VECTOR_KEY_SECP256K1)) },
+		hex:    "01223142487736786a5744584470646338544d4856487639714a37765272706e794c693322314b464845377738426861454e417377777279616f636344623671635436446259593fc000003e800000410179be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b84000000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002",
+		sha256: "c60fb361f13d8b81091fa0f8b188011858e31f1203f11a6afc01f4d1da470002",
+	},
+	{
+		name:   "ed25519 signed transaction",
+		encode: func() []byte { return EncodeSignedTransaction(vectorTransaction(ED25519, VECTOR_KEY_ED25519)) },
+		hex:    "01223142487736786a5744584470646338544d4856487639714a37765272706e794c693322314b464845377738426861454e417377777279616f636344623671635436446259593fc000003e8000002102d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a4000000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002",
+		sha256: "e0b7cc325f0e82d5475109c3a2ea1c41ecf4f6a74d6edf3570b3688dbf301e29",
+	},
+	{
+		name:   "transaction with a nonce",
+		encode: func() []byte { return EncodeTransaction(&vectorNonceTransaction().Tx) },
+		hex:    "02223142487736786a5744584470646338544d4856487639714a37765272706e794c693322314b464845377738426861454e417377777279616f636344623671635436446259593fc000003e8000000000000000000007",
+		sha256: "4bc0d622a0b0d4d18168dd1f7d308faf1ef4c9825e5753be4bc6471acc921566",
+	},
+	{
+		name:   "signed transaction with a nonce",
+		encode: func() []byte { return EncodeSignedTransaction(vectorNonceTransaction()) },
+		hex:    "02223142487736786a5744584470646338544d4856487639714a37765272706e794c693322314b464845377738426861454e417377777279616f636344623671635436446259593fc000003e8000000000000000000007406b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c2964fe342e2fe1a7f9b8ee7eb4a7c0f9e162bce33576b315ececbb6406837bf51f54000000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002",
+		sha256: "f8d40aec4cd6c4b00846a41fb3d9191fa83463a38db5adf5c03dee1b72569873",
+	},
+	{
+		name:   "multisig transaction",
+		encode: func() []byte { return EncodeSignedTransaction(vectorMultisigTransaction()) },
+		hex:    "01223142487736786a5744584470646338544d4856487639714a37765272706e794c693322314b464845377738426861454e417377777279616f636344623671635436446259593fc000003e8000000000a7010203410179be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b82102d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a406b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c2964fe342e2fe1a7f9b8ee7eb4a7c0f9e162bce33576b315ececbb6406837bf51f54000000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002004000000000000000000000000000000000000000000000000000000000000000030000000000000000000000000000000000000000000000000000000000000004",
+		sha256: "a6fac5712259015d72fdcdc00ba1e0ab416b1b67eeb3c47dfe5298c62cfa8b09",
+	},
+	{
+		name: "coinbase transaction",
+		encode: func() []byte {
+			return EncodeSignedTransaction(&Transaction{Tx: BlockTransaction{
+				SenderAddress:    "THE BLOCKCHAIN",
+				RecipientAddress: "1KFHE7w8BhaENAswwryaoccDb6qcT6DbYY",
+				Value:            50,
+			}})
+		},
+		hex:    "010e54484520424c4f434b434841494e22314b464845377738426861454e417377777279616f6363446236716354364462595942480000000000000000",
+		sha256: "6639e14ff3dcb37c81afab83444cd05b32b933263fe4eaa3e305ac1cfd0c6655",
+	},
+	{
+		name: "block header",
+		encode: func() []byte {
+			var previousHash [32]byte
+			previousHash[31] = 0xff
+			th := TransactionsHash([]*Transaction{vectorTransaction(P256, VECTOR_KEY_P256)})
+			return EncodeBlockHeader(1648166400000000000, 42, previousHash, th)
+		},
+		hex:    "0116df769bc2bc0000000000000000002a00000000000000000000000000000000000000000000000000000000000000ffb676bc6c8fd85d1ab3e6108a0ddcc76ce3f11a6a7886649c8bf8ad5431ab8154",
+		sha256: "72f3a8c6b89c5579d50a15175f8d2e0bd4ef21af35bc8fc27d2cb0d28061b107",
+	},
+}
+
+func TestEncodingVectors(t *testing.T) {
+	for _, v := range encodingVectors {
+		t.Run(v.name, func(t *testing.T) {
+			e := v.encode()
+			if got := hex.EncodeToString(e); got != v.hex {
+				t.Errorf("encoding = %s, want %s", got, v.hex)
+			}
+			sum := sha256.Sum256(e)
+			if got := hex.EncodeToString(sum[:]); got != v.sha256 {
+				t.Errorf("sha256 = %s, want %s", got, v.sha256)
+			}
+		})
+	}
+}
@@ -10,3 +10,14 @@ func JsonStatus(message string) []byte {
 	})
 	return m
 }
+
+func JsonTransactionID(message string, id string) []byte {
+	m, _ := json.Marshal(struct {
+		Message string `json:"message"`
+		ID      string `json:"id"`
+	}{
+		Message: message,
+		ID:      id,
+	})
+	return m
+}
@@ -0,0 +1,143 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/btcsuite/btcutil/base58"
+	"golang.org/x/crypto/ripemd160"
+	"sort"
+)
+
+// MULTISIG_MAX_KEYS bounds the keys of a multisig policy, as Bitcoin's
+// CHECKMULTISIG does for standard scripts.
+const MULTISIG_MAX_KEYS = 15
+
+var ErrMultisig = errors.New("invalid multisig policy")
+
+// Multisig is an M-of-N policy: funds sent to its address are spent by
+// transactions signed with M of its N keys. Keys are sorted by their
+// canonical encoding, so that a set of keys and M make a single address
+// whatever order they are given in.
+type Multisig struct {
+	M    int
+	Keys []*PublicKey
+}
+
+// NewMultisig makes the policy of m signatures out of keys.
+func NewMultisig(m int, keys []*PublicKey) (*Multisig, error) {
+	ms := &Multisig{M: m, Keys: append([]*PublicKey{}, keys...)}
+	for _, k := range ms.Keys {
+		if k == nil {
+			return nil, fmt.Errorf("%w: missing key", ErrMultisig)
+		}
+	}
+	sort.Slice(ms.Keys, func(i, j int) bool {
+		return bytes.Compare(EncodePublicKey(ms.Keys[i]), EncodePublicKey(ms.Keys[j])) < 0
+	})
+	if err := ms.Check(); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+// Check verifies 1 <= M <= N <= MULTISIG_MAX_KEYS and that the keys are
+// valid keys of their schemes, sorted and distinct.
+func (ms *Multisig) Check() error {
+	n := len(ms.Keys)
+	if n == 0 || n > MULTISIG_MAX_KEYS {
+		return fmt.Errorf("%w: %d keys, 1 to %d allowed", ErrMultisig, n, MULTISIG_MAX_KEYS)
+	}
+	if ms.M < 1 || ms.M > n {
+		return fmt.Errorf("%w: %d of %d signatures", ErrMultisig, ms.M, n)
+	}
+	var previous []byte
+	for _, k := range ms.Keys {
+		if k == nil || k.Scheme == nil {
+			return fmt.Errorf("%w: missing key", ErrMultisig)
+		}
+		if _, err := k.Scheme.ParsePublicKey(k.Key); err != nil {
+			return fmt.Errorf("%w: %v", ErrMultisig, err)
+		}
+		e := EncodePublicKey(k)
+		if previous != nil && bytes.Compare(previous, e) >= 0 {
+			return fmt.Errorf("%w: keys repeated or out of order", ErrMultisig)
+		}
+		previous = e
+	}
+	return nil
+}
+
+// IndexOf returns the position of publicKey among the keys of ms, or -1.
+func (ms *Multisig) IndexOf(publicKey *PublicKey) int {
+	e := EncodePublicKey(publicKey)
+	for i, k := range ms.Keys {
+		if bytes.Equal(EncodePublicKey(k), e) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Address is the Base58Check address of ms on network: the RIPEMD-160 of
+// the SHA-256 of its encoding under the network's multisig version byte.
+func (ms *Multisig) Address(network *Network) string {
+	h := sha256.Sum256(EncodeMultisig(ms))
+	r := ripemd160.New()
+	r.Write(h[:])
+	return base58.CheckEncode(r.Sum(nil), network.MultisigVersion)
+}
+
+// MultisigKey is a key of a multisig policy in JSON.
+type MultisigKey struct {
+	Scheme    string `json:"scheme"`
+	PublicKey string `json:"public_key"`
+}
+
+// MarshalJSON writes ms as its M and its keys in hex, each with its
+// scheme.
+func (ms *Multisig) MarshalJSON() ([]byte, error) {
+	keys := make([]MultisigKey, len(ms.Keys))
+	for i, k := range ms.Keys {
+		keys[i] = MultisigKey{Scheme: k.Scheme.Name(), PublicKey: k.String()}
+	}
+	return json.Marshal(struct {
+		M    int           `json:"m"`
+		Keys []MultisigKey `json:"keys"`
+	}{ms.M, keys})
+}
+
+// UnmarshalJSON reads a policy as written by MarshalJSON. Its keys must be
+// in order already: they are the ones its signatures are lined up with.
+func (ms *Multisig) UnmarshalJSON(m []byte) error {
+	var mj struct {
+		M    int           `json:"m"`
+		Keys []MultisigKey `json:"keys"`
+	}
+	if err := json.Unmarshal(m, &mj); err != nil {
+		return err
+	}
+	keys, err := MultisigKeys(mj.Keys)
+	if err != nil {
+		return err
+	}
+	ms.M, ms.Keys = mj.M, keys
+	return ms.Check()
+}
+
+// MultisigKeys reads the public keys of keys.
+func MultisigKeys(keys []MultisigKey) ([]*PublicKey, error) {
+	publicKeys := make([]*PublicKey, len(keys))
+	for i, k := range keys {
+		scheme, err := SchemeByName(k.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		if publicKeys[i], err = PublicKeyFromString(scheme, k.PublicKey); err != nil {
+			return nil, fmt.Errorf("%w: key %d: %v", ErrMultisig, i, err)
+		}
+	}
+	return publicKeys, nil
+}
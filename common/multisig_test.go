@@ -0,0 +1,92 @@
+package common
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func generatePublicKey(t *testing.T, scheme SignatureScheme) *PublicKey {
+	t.Helper()
+	d, err := scheme.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := scheme.PublicKey(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestNewMultisig(t *testing.T) {
+	keys := []*PublicKey{
+		generatePublicKey(t, P256),
+		generatePublicKey(t, SECP256K1),
+		generatePublicKey(t, ED25519),
+	}
+	ms, err := NewMultisig(2, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reversed, err := NewMultisig(2, []*PublicKey{keys[2], keys[1], keys[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms.Address(REGTEST) != reversed.Address(REGTEST) {
+		t.Error("the address depends on the order of the keys")
+	}
+	if !REGTEST.IsMultisigAddress(ms.Address(REGTEST)) {
+		t.Errorf("%s is not a multisig address", ms.Address(REGTEST))
+	}
+	for _, k := range keys {
+		if i := ms.IndexOf(k); i < 0 || ms.Keys[i] != k {
+			t.Errorf("IndexOf() = %d", i)
+		}
+	}
+	if other, _ := NewMultisig(1, keys); other.Address(REGTEST) == ms.Address(REGTEST) {
+		t.Error("policies of a different m share an address")
+	}
+}
+
+func TestMultisigCheck(t *testing.T) {
+	a, b := generatePublicKey(t, SECP256K1), generatePublicKey(t, ED25519)
+	offCurve, _ := hex.DecodeString("0200000000000000000000000000000000000000000000000000000000000000")
+	many := make([]*PublicKey, MULTISIG_MAX_KEYS+1)
+	for i := range many {
+		many[i] = generatePublicKey(t, ED25519)
+	}
+	tests := []struct {
+		name string
+		m    int
+		keys []*PublicKey
+		ok   bool
+	}{
+		{"1 of 1", 1, []*PublicKey{a}, true},
+		{"2 of 2", 2, []*PublicKey{a, b}, true},
+		{"no keys", 1, nil, false},
+		{"0 of 2", 0, []*PublicKey{a, b}, false},
+		{"3 of 2", 3, []*PublicKey{a, b}, false},
+		{"too many keys", 1, many, false},
+		{"repeated key", 1, []*PublicKey{a, a}, false},
+		{"missing key", 1, []*PublicKey{a, nil}, false},
+		{"key off the curve", 1, []*PublicKey{a, {Scheme: ED25519, Key: offCurve}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMultisig(tt.m, tt.keys)
+			if tt.ok != (err == nil) {
+				t.Fatalf("NewMultisig() = %v", err)
+			}
+			if err != nil && !errors.Is(err, ErrMultisig) {
+				t.Errorf("NewMultisig() = %v, want %v", err, ErrMultisig)
+			}
+		})
+	}
+
+	ms, _ := NewMultisig(1, []*PublicKey{a, b})
+	ms.Keys[0], ms.Keys[1] = ms.Keys[1], ms.Keys[0]
+	if err := ms.Check(); !errors.Is(err, ErrMultisig) {
+		t.Errorf("Check() of unsorted keys = %v, want %v", err, ErrMultisig)
+	}
+}
@@ -0,0 +1,176 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"github.com/btcsuite/btcutil/base58"
+	"golang.org/x/crypto/ripemd160"
+	"strings"
+)
+
+type Network struct {
+	Name             string
+	AddressVersion   byte
+	MultisigVersion  byte   // version byte of multisig addresses
+	Bech32HRP        string // human-readable part of Bech32 addresses
+	WIFVersion       byte   // version byte of private keys in WIF
+	DefaultPort      uint16
+	PortRangeStart   uint16
+	PortRangeEnd     uint16
+	WalletPort       uint16
+	Difficulty       int
+	GenesisTimestamp int64
+	GenesisNonce     int
+	Emission         EmissionSchedule
+	Faucet           bool
+	HDCoinType       uint32 // BIP-44 coin type of HD wallet paths
+}
+
+var (
+	MAINNET = &Network{
+		Name:             "mainnet",
+		AddressVersion:   0x00,
+		MultisigVersion:  0x05,
+		Bech32HRP:        "gbc",
+		WIFVersion:       0x80,
+		DefaultPort:      5000,
+		PortRangeStart:   5000,
+		PortRangeEnd:     5003,
+		WalletPort:       8080,
+		Difficulty:       3,
+		GenesisTimestamp: 1648166400000000000, // 2022-03-25 00:00:00 UTC
+		GenesisNonce:     0,
+		Emission:         EmissionSchedule{InitialReward: 1.0, HalvingInterval: 100000, MaxSupply: 200000},
+		HDCoinType:       5000,
+	}
+	TESTNET = &Network{
+		Name:             "testnet",
+		AddressVersion:   0x6f,
+		MultisigVersion:  0xc4,
+		Bech32HRP:        "tgbc",
+		WIFVersion:       0xef,
+		DefaultPort:      6000,
+		PortRangeStart:   6000,
+		PortRangeEnd:     6003,
+		WalletPort:       8180,
+		Difficulty:       2,
+		GenesisTimestamp: 1648252800000000000, // 2022-03-26 00:00:00 UTC
+		GenesisNonce:     1,
+		Emission:         EmissionSchedule{InitialReward: 50.0, HalvingInterval: 1000, MaxSupply: 100000},
+		Faucet:           true,
+		HDCoinType:       1, // SLIP-44 "testnet, all coins"
+	}
+	REGTEST = &Network{
+		Name:             "regtest",
+		AddressVersion:   0x3c,
+		MultisigVersion:  0x3f,
+		Bech32HRP:        "rgbc",
+		WIFVersion:       0xbc,
+		DefaultPort:      7000,
+		PortRangeStart:   7000,
+		PortRangeEnd:     7003,
+		WalletPort:       8280,
+		Difficulty:       1,
+		GenesisTimestamp: 1648339200000000000, // 2022-03-27 00:00:00 UTC
+		GenesisNonce:     2,
+		Emission:         EmissionSchedule{InitialReward: 50.0, HalvingInterval: 150, MaxSupply: 15000},
+		Faucet:           true,
+		HDCoinType:       1, // SLIP-44 "testnet, all coins"
+	}
+)
+
+var NETWORKS = []*Network{MAINNET, TESTNET, REGTEST}
+
+func NetworkByName(name string) (*Network, error) {
+	for _, n := range NETWORKS {
+		if n.Name == name {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown network %q", name)
+}
+
+// AddressVersion returns the version byte a Base58 address was built with,
+// which identifies the network the address belongs to.
+func AddressVersion(address string) (byte, error) {
+	b := base58.Decode(address)
+	if len(b) == 0 {
+		return 0, fmt.Errorf("invalid address %q", address)
+	}
+	return b[0], nil
+}
+
+// ValidateAddress checks that address is a well-formed address of this
+// network, of a key or a multisig policy, with a valid checksum.
+func (n *Network) ValidateAddress(address string) error {
+	if n.IsMultisigAddress(address) {
+		return nil
+	}
+	return ValidateAddress(address, n.AddressVersion)
+}
+
+// IsMultisigAddress reports whether address is a valid multisig address of
+// this network.
+func (n *Network) IsMultisigAddress(address string) bool {
+	return ValidateAddress(address, n.MultisigVersion) == nil
+}
+
+// IsAddressOf reports whether address is a valid address of this network.
+func (n *Network) IsAddressOf(address string) bool {
+	return n.ValidateAddress(address) == nil
+}
+
+// Bech32Address re-encodes a Base58Check address of this network as
+// Bech32m under its human-readable part. Both forms name the same key
+// hash.
+func (n *Network) Bech32Address(address string) (string, error) {
+	if err := n.ValidateAddress(address); err != nil {
+		return "", err
+	}
+	hash, version, _ := base58.CheckDecode(address)
+	data, _ := ConvertBits(hash, 8, 5, true)
+	kind := byte(ADDRESS_KEY_VERSION)
+	if version == n.MultisigVersion {
+		kind = ADDRESS_MULTISIG_VERSION
+	}
+	return EncodeBech32(n.Bech32HRP, append([]byte{kind}, data...), BECH32M)
+}
+
+// ParseAddress accepts an address of this network in Base58Check or
+// Bech32/Bech32m form and returns its Base58Check form, the one
+// transactions carry and balances are kept by.
+func (n *Network) ParseAddress(address string) (string, error) {
+	if !isBech32Address(address) {
+		return address, n.ValidateAddress(address)
+	}
+	hrp, data, _, err := DecodeBech32(address)
+	if errors.Is(err, ErrBech32Checksum) {
+		return "", fmt.Errorf("%w: %q", ErrAddressChecksum, address)
+	}
+	if err != nil || len(data) == 0 || (data[0] != ADDRESS_KEY_VERSION && data[0] != ADDRESS_MULTISIG_VERSION) {
+		return "", fmt.Errorf("%w: %q", ErrAddressEncoding, address)
+	}
+	if hrp != n.Bech32HRP {
+		return "", fmt.Errorf("%w: %q", ErrAddressVersion, address)
+	}
+	hash, err := ConvertBits(data[1:], 5, 8, false)
+	if err != nil || len(hash) != ripemd160.Size {
+		return "", fmt.Errorf("%w: %q", ErrAddressLength, address)
+	}
+	if data[0] == ADDRESS_MULTISIG_VERSION {
+		return base58.CheckEncode(hash, n.MultisigVersion), nil
+	}
+	return base58.CheckEncode(hash, n.AddressVersion), nil
+}
+
+// isBech32Address tells whether address starts like a Bech32 address of
+// any network, which no Base58 address of ours does.
+func isBech32Address(address string) bool {
+	address = strings.ToLower(address)
+	for _, n := range NETWORKS {
+		if strings.HasPrefix(address, n.Bech32HRP+string(BECH32_SEPARATOR)) {
+			return true
+		}
+	}
+	return false
+}
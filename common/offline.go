@@ -0,0 +1,132 @@
+package common
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// UNSIGNED_TX_VERSION 2 added the nonce.
+const UNSIGNED_TX_VERSION = 2
+
+var ErrUnsignedTransaction = errors.New("invalid unsigned transaction")
+
+// UnsignedTransaction is a transaction built by a watch-only wallet for a
+// signer holding the key offline. Besides the transaction, it carries
+// what the wallet knew when building it, for the signer to review: the
+// sender's confirmed balance and the chain tip. The nonce ties it to the
+// sender's transactions so far: signed, it is accepted only as their next.
+type UnsignedTransaction struct {
+	Version          int     `json:"version"`
+	Network          string  `json:"network"`
+	SenderAddress    string  `json:"sender_address"`
+	RecipientAddress string  `json:"recipient_address"`
+	Value            float32 `json:"value"`
+	Fee              float32 `json:"fee"`
+	Nonce            uint64  `json:"nonce"`
+	SigningHash      string  `json:"signing_hash"`
+	Balance          float64 `json:"balance"`
+	Height           int     `json:"height"`
+	TipHash          string  `json:"tip_hash"`
+	CreatedAt        int64   `json:"created_at"`
+}
+
+func NewUnsignedTransaction(network *Network, t *BlockTransaction) *UnsignedTransaction {
+	h := TransactionSigningHash(t)
+	return &UnsignedTransaction{
+		Version:          UNSIGNED_TX_VERSION,
+		Network:          network.Name,
+		SenderAddress:    t.SenderAddress,
+		RecipientAddress: t.RecipientAddress,
+		Value:            t.Value,
+		Fee:              t.Fee,
+		Nonce:            t.Nonce,
+		SigningHash:      hex.EncodeToString(h[:]),
+	}
+}
+
+func (u *UnsignedTransaction) BlockTransaction() *BlockTransaction {
+	return &BlockTransaction{
+		SenderAddress:    u.SenderAddress,
+		RecipientAddress: u.RecipientAddress,
+		Value:            u.Value,
+		Fee:              u.Fee,
+		Nonce:            u.Nonce,
+	}
+}
+
+// Check verifies u was built for network and that its signing hash is the
+// one of its content, which is what gets signed.
+func (u *UnsignedTransaction) Check(network *Network) error {
+	if u.Version != UNSIGNED_TX_VERSION {
+		return fmt.Errorf("%w: version %d", ErrUnsignedTransaction, u.Version)
+	}
+	if u.Network != network.Name {
+		return fmt.Errorf("%w: built for %s", ErrUnsignedTransaction, u.Network)
+	}
+	if !network.IsAddressOf(u.SenderAddress) || !network.IsAddressOf(u.RecipientAddress) {
+		return fmt.Errorf("%w: address not on %s", ErrUnsignedTransaction, network.Name)
+	}
+	if u.Value <= 0 || u.Fee < 0 {
+		return fmt.Errorf("%w: bad value or fee", ErrUnsignedTransaction)
+	}
+	h := TransactionSigningHash(u.BlockTransaction())
+	if u.SigningHash != hex.EncodeToString(h[:]) {
+		return fmt.Errorf("%w: signing hash mismatch", ErrUnsignedTransaction)
+	}
+	return nil
+}
+
+// Encode is u as a single base64 string, easier to carry than a file.
+func (u *UnsignedTransaction) Encode() string {
+	m, _ := json.Marshal(u)
+	return base64.StdEncoding.EncodeToString(m)
+}
+
+// unarmor returns the JSON of data, which is either JSON or its base64
+// encoding.
+func unarmor(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("{")) {
+		return data, nil
+	}
+	return base64.StdEncoding.DecodeString(string(data))
+}
+
+// DecodeUnsignedTransaction reads an unsigned transaction saved as JSON or
+// encoded with Encode.
+func DecodeUnsignedTransaction(data []byte) (*UnsignedTransaction, error) {
+	m, err := unarmor(data)
+	if err != nil {
+		return nil, ErrUnsignedTransaction
+	}
+	u := new(UnsignedTransaction)
+	if err := json.Unmarshal(m, u); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsignedTransaction, err)
+	}
+	return u, nil
+}
+
+// EncodeSignedTransactionString is the counterpart of Encode for the
+// signed transaction, in the JSON the gateway accepts.
+func EncodeSignedTransactionString(t *Transaction) string {
+	m, _ := json.Marshal(t)
+	return base64.StdEncoding.EncodeToString(m)
+}
+
+// DecodeSignedTransaction reads a signed transaction as JSON or encoded
+// with EncodeSignedTransactionString.
+func DecodeSignedTransaction(data []byte) (*Transaction, error) {
+	m, err := unarmor(data)
+	if err != nil {
+		return nil, errors.New("invalid signed transaction")
+	}
+	t := new(Transaction)
+	if err := json.Unmarshal(m, t); err != nil {
+		return nil, fmt.Errorf("invalid signed transaction: %v", err)
+	}
+	return t, nil
+}
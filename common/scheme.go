@@ -0,0 +1,417 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"filippo.io/edwards25519"
+	"fmt"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"math/big"
+)
+
+const (
+	SCHEME_P256      = "p256"
+	SCHEME_SECP256K1 = "secp256k1"
+	SCHEME_ED25519   = "ed25519"
+
+	// Tags of the schemes in the canonical encoding of public keys. P-256
+	// keys, the only ones before schemes were tagged, are written without.
+	SCHEME_TAG_P256      = 0x00
+	SCHEME_TAG_SECP256K1 = 0x01
+	SCHEME_TAG_ED25519   = 0x02
+
+	// PRIVATE_KEY_SIZE is the size of the private key of every scheme: the
+	// scalar of the ECDSA curves, the seed of Ed25519.
+	PRIVATE_KEY_SIZE = 32
+)
+
+var (
+	ErrUnknownScheme = errors.New("unknown signature scheme")
+	ErrPublicKey     = errors.New("invalid public key")
+	ErrPrivateKey    = errors.New("invalid private key")
+)
+
+// SignatureScheme is a kind of key the sender of a transaction signs its
+// signing hash with. Private keys are PRIVATE_KEY_SIZE bytes; public keys
+// are in the encoding of the scheme, see PublicKey.
+type SignatureScheme interface {
+	Name() string
+	// Tag identifies the scheme in the canonical encoding.
+	Tag() byte
+	GenerateKey() ([]byte, error)
+	// PublicKey derives the public key of the private key d.
+	PublicKey(d []byte) (*PublicKey, error)
+	// ParsePublicKey checks key is a public key of the scheme, a point of
+	// its curve.
+	ParsePublicKey(key []byte) (*PublicKey, error)
+	// CheckSignature rejects signatures the scheme does not produce: values
+	// out of range and, on the ECDSA curves, the high one of the two S a
+	// signature can have, so that no one but the signer can turn it into
+	// another valid signature of the same transaction.
+	CheckSignature(signature *Signature) error
+	Sign(d []byte, digest []byte) (*Signature, error)
+	// Verify checks the key and the signature as ParsePublicKey and
+	// CheckSignature do before verifying the signature of digest.
+	Verify(publicKey *PublicKey, digest []byte, signature *Signature) bool
+}
+
+var (
+	P256      SignatureScheme = p256Scheme{}
+	SECP256K1 SignatureScheme = secp256k1Scheme{}
+	ED25519   SignatureScheme = ed25519Scheme{}
+
+	SIGNATURE_SCHEMES = []SignatureScheme{P256, SECP256K1, ED25519}
+)
+
+// SchemeByName returns the scheme called name. The empty name is P-256,
+// the scheme of transactions and key files written before schemes were
+// tagged.
+func SchemeByName(name string) (SignatureScheme, error) {
+	if name == "" {
+		return P256, nil
+	}
+	for _, s := range SIGNATURE_SCHEMES {
+		if s.Name() == name {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownScheme, name)
+}
+
+func SchemeByTag(tag byte) (SignatureScheme, error) {
+	for _, s := range SIGNATURE_SCHEMES {
+		if s.Tag() == tag {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: tag %d", ErrUnknownScheme, tag)
+}
+
+// PublicKey is a public key of Scheme, in its encoding: X | Y, each padded
+// to 32 bytes, on the ECDSA curves and the 32 bytes of RFC 8032 for
+// Ed25519.
+type PublicKey struct {
+	Scheme SignatureScheme
+	Key    []byte
+}
+
+func (k *PublicKey) String() string {
+	return hex.EncodeToString(k.Key)
+}
+
+func isECDSA(s SignatureScheme) bool {
+	return s.Tag() != SCHEME_TAG_ED25519
+}
+
+// xy splits the key of an ECDSA curve into its coordinates, failing if it
+// is not 64 bytes long.
+func (k *PublicKey) xy() (*big.Int, *big.Int, error) {
+	if len(k.Key) != 64 {
+		return nil, nil, ErrPublicKey
+	}
+	return new(big.Int).SetBytes(k.Key[:32]), new(big.Int).SetBytes(k.Key[32:]), nil
+}
+
+// addressBytes is what addresses hash: the coordinates without their
+// leading zeros on the ECDSA curves, as addresses always did, the key
+// itself for Ed25519.
+func (k *PublicKey) addressBytes() ([]byte, error) {
+	if !isECDSA(k.Scheme) {
+		return k.Key, nil
+	}
+	x, y, err := k.xy()
+	if err != nil {
+		return nil, err
+	}
+	return append(x.Bytes(), y.Bytes()...), nil
+}
+
+// MarshalJSON writes the coordinates of ECDSA keys as numbers, as the API
+// always did, and Ed25519 keys as hex.
+func (k *PublicKey) MarshalJSON() ([]byte, error) {
+	if !isECDSA(k.Scheme) {
+		return json.Marshal(k.String())
+	}
+	x, y, err := k.xy()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		X *big.Int
+		Y *big.Int
+	}{x, y})
+}
+
+// PublicKeyFromJSON is the inverse of PublicKey.MarshalJSON for a key of
+// scheme; null decodes to a nil key.
+func PublicKeyFromJSON(scheme SignatureScheme, m json.RawMessage) (*PublicKey, error) {
+	if len(m) == 0 || string(m) == "null" {
+		return nil, nil
+	}
+	if !isECDSA(scheme) {
+		var s string
+		if err := json.Unmarshal(m, &s); err != nil {
+			return nil, ErrPublicKey
+		}
+		return PublicKeyFromString(scheme, s)
+	}
+	var xy struct {
+		X *big.Int
+		Y *big.Int
+	}
+	if err := json.Unmarshal(m, &xy); err != nil || !fits(xy.X) || !fits(xy.Y) {
+		return nil, ErrPublicKey
+	}
+	return scheme.ParsePublicKey(append(padded(xy.X.Bytes()), padded(xy.Y.Bytes())...))
+}
+
+// fits reports whether n is a 32-byte unsigned integer.
+func fits(n *big.Int) bool {
+	return n != nil && n.Sign() >= 0 && n.BitLen() <= 256
+}
+
+// checkECDSA accepts signatures with 0 < R < n and 0 < S <= n/2.
+func checkECDSA(signature *Signature, n *big.Int) error {
+	if signature == nil || signature.R == nil || signature.S == nil ||
+		signature.R.Sign() <= 0 || signature.R.Cmp(n) >= 0 ||
+		signature.S.Sign() <= 0 || signature.S.Cmp(n) >= 0 {
+		return ErrSignatureEncoding
+	}
+	if signature.S.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		return ErrSignatureHighS
+	}
+	return nil
+}
+
+// lowS replaces S by n - S, as valid, if S is the high one.
+func lowS(signature *Signature, n *big.Int) *Signature {
+	if signature.S.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		signature.S = new(big.Int).Sub(n, signature.S)
+	}
+	return signature
+}
+
+func scalar(d []byte, n *big.Int) (*big.Int, error) {
+	k := new(big.Int).SetBytes(d)
+	if len(d) != PRIVATE_KEY_SIZE || k.Sign() == 0 || k.Cmp(n) >= 0 {
+		return nil, ErrPrivateKey
+	}
+	return k, nil
+}
+
+// P-256 ECDSA, with crypto/ecdsa.
+type p256Scheme struct{}
+
+func (p256Scheme) Name() string { return SCHEME_P256 }
+
+func (p256Scheme) Tag() byte { return SCHEME_TAG_P256 }
+
+func (p256Scheme) GenerateKey() ([]byte, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return privateKey.D.FillBytes(make([]byte, PRIVATE_KEY_SIZE)), nil
+}
+
+func (s p256Scheme) privateKey(d []byte) (*ecdsa.PrivateKey, error) {
+	curve := elliptic.P256()
+	k, err := scalar(d, curve.Params().N)
+	if err != nil {
+		return nil, err
+	}
+	privateKey := new(ecdsa.PrivateKey)
+	privateKey.Curve = curve
+	privateKey.D = k
+	privateKey.X, privateKey.Y = curve.ScalarBaseMult(d)
+	return privateKey, nil
+}
+
+func (s p256Scheme) PublicKey(d []byte) (*PublicKey, error) {
+	privateKey, err := s.privateKey(d)
+	if err != nil {
+		return nil, err
+	}
+	return &PublicKey{Scheme: s, Key: append(padded(privateKey.X.Bytes()), padded(privateKey.Y.Bytes())...)}, nil
+}
+
+func (s p256Scheme) ParsePublicKey(key []byte) (*PublicKey, error) {
+	if len(key) != 64 {
+		return nil, ErrPublicKey
+	}
+	curve := elliptic.P256()
+	x, y := new(big.Int).SetBytes(key[:32]), new(big.Int).SetBytes(key[32:])
+	if x.Cmp(curve.Params().P) >= 0 || y.Cmp(curve.Params().P) >= 0 || !curve.IsOnCurve(x, y) {
+		return nil, ErrPublicKey
+	}
+	return &PublicKey{Scheme: s, Key: key}, nil
+}
+
+func (p256Scheme) CheckSignature(signature *Signature) error {
+	return checkECDSA(signature, elliptic.P256().Params().N)
+}
+
+func (s p256Scheme) Sign(d []byte, digest []byte) (*Signature, error) {
+	privateKey, err := s.privateKey(d)
+	if err != nil {
+		return nil, err
+	}
+	r, ss, err := ecdsa.Sign(rand.Reader, privateKey, digest)
+	if err != nil {
+		return nil, err
+	}
+	return lowS(&Signature{R: r, S: ss}, privateKey.Params().N), nil
+}
+
+func (s p256Scheme) Verify(publicKey *PublicKey, digest []byte, signature *Signature) bool {
+	if _, err := s.ParsePublicKey(publicKey.Key); err != nil || s.CheckSignature(signature) != nil {
+		return false
+	}
+	x, y, _ := publicKey.xy()
+	return ecdsa.Verify(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, digest, signature.R, signature.S)
+}
+
+// secp256k1 ECDSA, with the curve of Bitcoin's keys. Signatures are
+// deterministic, RFC 6979.
+type secp256k1Scheme struct{}
+
+func (secp256k1Scheme) Name() string { return SCHEME_SECP256K1 }
+
+func (secp256k1Scheme) Tag() byte { return SCHEME_TAG_SECP256K1 }
+
+func (secp256k1Scheme) GenerateKey() ([]byte, error) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	return privateKey.Serialize(), nil
+}
+
+func (secp256k1Scheme) privateKey(d []byte) (*secp256k1.PrivateKey, error) {
+	var k secp256k1.ModNScalar
+	if len(d) != PRIVATE_KEY_SIZE || k.SetByteSlice(d) || k.IsZero() {
+		return nil, ErrPrivateKey
+	}
+	return secp256k1.NewPrivateKey(&k), nil
+}
+
+func (s secp256k1Scheme) PublicKey(d []byte) (*PublicKey, error) {
+	privateKey, err := s.privateKey(d)
+	if err != nil {
+		return nil, err
+	}
+	// uncompressed: 0x04 | X | Y
+	return &PublicKey{Scheme: s, Key: privateKey.PubKey().SerializeUncompressed()[1:]}, nil
+}
+
+func (s secp256k1Scheme) ParsePublicKey(key []byte) (*PublicKey, error) {
+	if len(key) != 64 {
+		return nil, ErrPublicKey
+	}
+	if _, err := secp256k1.ParsePubKey(append([]byte{0x04}, key...)); err != nil {
+		return nil, ErrPublicKey
+	}
+	return &PublicKey{Scheme: s, Key: key}, nil
+}
+
+func (secp256k1Scheme) CheckSignature(signature *Signature) error {
+	return checkECDSA(signature, secp256k1.Params().N)
+}
+
+func (s secp256k1Scheme) Sign(d []byte, digest []byte) (*Signature, error) {
+	privateKey, err := s.privateKey(d)
+	if err != nil {
+		return nil, err
+	}
+	// compact: recovery code | R | S, S already low
+	sig := secp256k1ecdsa.SignCompact(privateKey, digest, false)
+	return lowS(&Signature{R: new(big.Int).SetBytes(sig[1:33]), S: new(big.Int).SetBytes(sig[33:])}, secp256k1.Params().N), nil
+}
+
+func (sc secp256k1Scheme) Verify(publicKey *PublicKey, digest []byte, signature *Signature) bool {
+	if len(publicKey.Key) != 64 || sc.CheckSignature(signature) != nil {
+		return false
+	}
+	key, err := secp256k1.ParsePubKey(append([]byte{0x04}, publicKey.Key...))
+	if err != nil {
+		return false
+	}
+	var r, s secp256k1.ModNScalar
+	r.SetByteSlice(signature.R.Bytes())
+	s.SetByteSlice(signature.S.Bytes())
+	return secp256k1ecdsa.NewSignature(&r, &s).Verify(digest, key)
+}
+
+// Ed25519, RFC 8032. The private key is the seed and the 64 bytes of a
+// signature are carried as R and S, each read as a big-endian integer.
+// Signatures are not malleable as long as S, little-endian, is below the
+// order of the group.
+type ed25519Scheme struct{}
+
+var ed25519Order, _ = new(big.Int).SetString("1000000000000000000000000000000014def9dea2f79cd65812631a5cf5d3ed", 16)
+
+func (ed25519Scheme) Name() string { return SCHEME_ED25519 }
+
+func (ed25519Scheme) Tag() byte { return SCHEME_TAG_ED25519 }
+
+func (ed25519Scheme) GenerateKey() ([]byte, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return privateKey.Seed(), nil
+}
+
+func (s ed25519Scheme) PublicKey(d []byte) (*PublicKey, error) {
+	if len(d) != ed25519.SeedSize {
+		return nil, ErrPrivateKey
+	}
+	publicKey := ed25519.NewKeyFromSeed(d).Public().(ed25519.PublicKey)
+	return &PublicKey{Scheme: s, Key: []byte(publicKey)}, nil
+}
+
+// ParsePublicKey accepts the encodings of points of the curve only.
+func (s ed25519Scheme) ParsePublicKey(key []byte) (*PublicKey, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, ErrPublicKey
+	}
+	if _, err := new(edwards25519.Point).SetBytes(key); err != nil {
+		return nil, ErrPublicKey
+	}
+	return &PublicKey{Scheme: s, Key: key}, nil
+}
+
+func (ed25519Scheme) CheckSignature(signature *Signature) error {
+	if signature == nil || !fits(signature.R) || !fits(signature.S) {
+		return ErrSignatureEncoding
+	}
+	b := padded(signature.S.Bytes())
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	if new(big.Int).SetBytes(b).Cmp(ed25519Order) >= 0 {
+		return ErrSignatureEncoding
+	}
+	return nil
+}
+
+func (ed25519Scheme) Sign(d []byte, digest []byte) (*Signature, error) {
+	if len(d) != ed25519.SeedSize {
+		return nil, ErrPrivateKey
+	}
+	sig := ed25519.Sign(ed25519.NewKeyFromSeed(d), digest)
+	return &Signature{R: new(big.Int).SetBytes(sig[:32]), S: new(big.Int).SetBytes(sig[32:])}, nil
+}
+
+func (s ed25519Scheme) Verify(publicKey *PublicKey, digest []byte, signature *Signature) bool {
+	if _, err := s.ParsePublicKey(publicKey.Key); err != nil || s.CheckSignature(signature) != nil {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(publicKey.Key), digest, EncodeSignature(signature))
+}
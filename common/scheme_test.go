@@ -0,0 +1,203 @@
+package common
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"math/big"
+	"testing"
+)
+
+func TestPublicKeyWrongLength(t *testing.T) {
+	for _, scheme := range []SignatureScheme{P256, SECP256K1} {
+		for _, key := range [][]byte{nil, make([]byte, 31), make([]byte, 63), make([]byte, 65)} {
+			k := &PublicKey{Scheme: scheme, Key: key}
+			if _, err := json.Marshal(k); !errors.Is(err, ErrPublicKey) {
+				t.Errorf("%s: json.Marshal() of a %d-byte key = %v, want %v", scheme.Name(), len(key), err, ErrPublicKey)
+			}
+			if address := AddressFromPublicKey(k, REGTEST.AddressVersion); address != "" {
+				t.Errorf("%s: AddressFromPublicKey() of a %d-byte key = %q", scheme.Name(), len(key), address)
+			}
+		}
+	}
+}
+
+// reversed is b in reverse order, for the little-endian S of Ed25519.
+func reversed(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
+
+func TestEd25519Vector(t *testing.T) {
+	// RFC 8032, section 7.1, test 1
+	seed, _ := hex.DecodeString("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	want := "e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e065224901555fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b"
+	k, err := ED25519.PublicKey(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := k.String(); got != "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a" {
+		t.Errorf("PublicKey() = %s", got)
+	}
+	signature, err := ED25519.Sign(seed, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(EncodeSignature(signature)); got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+	if !ED25519.Verify(k, nil, signature) {
+		t.Error("Verify() of the RFC 8032 signature failed")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	digest := sha256.Sum256([]byte("transaction"))
+	other := sha256.Sum256([]byte("another transaction"))
+	for _, scheme := range SIGNATURE_SCHEMES {
+		t.Run(scheme.Name(), func(t *testing.T) {
+			d, err := scheme.GenerateKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			k, err := scheme.PublicKey(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signature, err := scheme.Sign(d, digest[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := scheme.CheckSignature(signature); err != nil {
+				t.Errorf("CheckSignature() of a signature of the scheme = %v", err)
+			}
+			if !scheme.Verify(k, digest[:], signature) {
+				t.Error("Verify() failed")
+			}
+			if scheme.Verify(k, other[:], signature) {
+				t.Error("Verify() of another digest")
+			}
+			if scheme.Verify(generatePublicKey(t, scheme), digest[:], signature) {
+				t.Error("Verify() with another key")
+			}
+			if _, err := scheme.ParsePublicKey(k.Key); err != nil {
+				t.Errorf("ParsePublicKey() of its key = %v", err)
+			}
+			if _, err := scheme.PublicKey(make([]byte, PRIVATE_KEY_SIZE-1)); !errors.Is(err, ErrPrivateKey) {
+				t.Errorf("PublicKey() of a short private key = %v, want %v", err, ErrPrivateKey)
+			}
+		})
+	}
+}
+
+func TestCheckSignature(t *testing.T) {
+	digest := sha256.Sum256([]byte("transaction"))
+	orders := map[SignatureScheme]*big.Int{
+		P256:      elliptic.P256().Params().N,
+		SECP256K1: secp256k1.Params().N,
+	}
+	for _, scheme := range SIGNATURE_SCHEMES {
+		d, err := scheme.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		k, _ := scheme.PublicKey(d)
+		valid, err := scheme.Sign(d, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		type test struct {
+			name      string
+			signature *Signature
+			err       error
+		}
+		one := big.NewInt(1)
+		tests := []test{
+			{"nil", nil, ErrSignatureEncoding},
+			{"no R", &Signature{S: valid.S}, ErrSignatureEncoding},
+			{"negative S", &Signature{R: valid.R, S: big.NewInt(-1)}, ErrSignatureEncoding},
+			{"longer than 32 bytes", &Signature{R: valid.R, S: new(big.Int).Lsh(one, 256)}, ErrSignatureEncoding},
+		}
+		if n, ok := orders[scheme]; ok {
+			tests = append(tests, []test{
+				{"zero R", &Signature{R: new(big.Int), S: valid.S}, ErrSignatureEncoding},
+				{"zero S", &Signature{R: valid.R, S: new(big.Int)}, ErrSignatureEncoding},
+				{"R of the order", &Signature{R: n, S: valid.S}, ErrSignatureEncoding},
+				{"S of the order", &Signature{R: valid.R, S: n}, ErrSignatureEncoding},
+				{"high S", &Signature{R: valid.R, S: new(big.Int).Sub(n, valid.S)}, ErrSignatureHighS},
+			}...)
+		} else {
+			// S + L, little-endian, verifies too unless rejected
+			s := new(big.Int).SetBytes(reversed(padded(valid.S.Bytes())))
+			s.Add(s, ed25519Order)
+			tests = append(tests, test{"S not reduced", &Signature{R: valid.R, S: new(big.Int).SetBytes(reversed(padded(s.Bytes())))}, ErrSignatureEncoding})
+		}
+		for _, tt := range tests {
+			t.Run(scheme.Name()+"/"+tt.name, func(t *testing.T) {
+				if err := scheme.CheckSignature(tt.signature); !errors.Is(err, tt.err) {
+					t.Errorf("CheckSignature() = %v, want %v", err, tt.err)
+				}
+				if scheme.Verify(k, digest[:], tt.signature) {
+					t.Error("Verify() accepted the signature")
+				}
+			})
+		}
+	}
+}
+
+func TestDecodeSignature(t *testing.T) {
+	for _, n := range []int{1, 63, 65, 72} {
+		if _, err := DecodeSignature(make([]byte, n)); err == nil {
+			t.Errorf("DecodeSignature() of %d bytes succeeded", n)
+		}
+	}
+	if s, err := DecodeSignature(nil); s != nil || err != nil {
+		t.Errorf("DecodeSignature(nil) = %v, %v", s, err)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	// (1, 1) is on neither ECDSA curve, y = 2 encodes no Ed25519 point
+	offCurve := append(padded([]byte{1}), padded([]byte{1})...)
+	ed25519OffCurve, _ := hex.DecodeString("0200000000000000000000000000000000000000000000000000000000000000")
+	beyondP := append(padded(elliptic.P256().Params().P.Bytes()), padded([]byte{1})...)
+	type test struct {
+		scheme SignatureScheme
+		name   string
+		key    []byte
+	}
+	tests := []test{
+		{P256, "off the curve", offCurve},
+		{P256, "coordinate beyond p", beyondP},
+		{P256, "point at infinity", make([]byte, 64)},
+		{SECP256K1, "off the curve", offCurve},
+		{SECP256K1, "point at infinity", make([]byte, 64)},
+		{ED25519, "off the curve", ed25519OffCurve},
+	}
+	for _, scheme := range SIGNATURE_SCHEMES {
+		for _, n := range []int{0, 31, 33, 63, 65} {
+			if n != len(generatePublicKey(t, scheme).Key) {
+				tests = append(tests, test{scheme, fmt.Sprintf("%d bytes", n), make([]byte, n)})
+			}
+		}
+	}
+	digest := sha256.Sum256([]byte("transaction"))
+	for _, tt := range tests {
+		t.Run(tt.scheme.Name()+"/"+tt.name, func(t *testing.T) {
+			if _, err := tt.scheme.ParsePublicKey(tt.key); !errors.Is(err, ErrPublicKey) {
+				t.Errorf("ParsePublicKey() = %v, want %v", err, ErrPublicKey)
+			}
+			signature := &Signature{R: big.NewInt(1), S: big.NewInt(1)}
+			if tt.scheme.Verify(&PublicKey{Scheme: tt.scheme, Key: tt.key}, digest[:], signature) {
+				t.Error("Verify() with the key")
+			}
+		})
+	}
+}
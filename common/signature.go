@@ -0,0 +1,34 @@
+package common
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var (
+	ErrSignatureEncoding = errors.New("invalid signature encoding")
+	ErrSignatureHighS    = errors.New("signature is not in low-S form")
+)
+
+// Signature is the (R, S) pair of an ECDSA signature, or the two halves
+// of an Ed25519 one.
+type Signature struct {
+	R *big.Int
+	S *big.Int
+}
+
+func (s *Signature) String() string {
+	return fmt.Sprintf("%064x%064x", s.R, s.S)
+}
+
+// PublicKeyFromString reads the hex of a public key of scheme, as written
+// by PublicKey.String.
+func PublicKeyFromString(scheme SignatureScheme, s string) (*PublicKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, ErrPublicKey
+	}
+	return scheme.ParsePublicKey(b)
+}
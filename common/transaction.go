@@ -1,17 +1,21 @@
 package common
 
 import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 )
 
+// BlockTransaction is the signed content of a transaction. Nonce counts
+// the transactions sent before by the same sender, so that no two of them
+// are alike and none can be replayed.
 type BlockTransaction struct {
 	SenderAddress    string
 	RecipientAddress string
 	Value            float32
+	Fee              float32
+	Nonce            uint64
 }
 
 func (t *BlockTransaction) Print() {
@@ -19,71 +23,259 @@ func (t *BlockTransaction) Print() {
 	fmt.Printf(" sender_address      %s\n", t.SenderAddress)
 	fmt.Printf(" recipient_address   %s\n", t.RecipientAddress)
 	fmt.Printf(" value               %.1f\n", t.Value)
+	fmt.Printf(" fee                 %.1f\n", t.Fee)
+	fmt.Printf(" nonce               %d\n", t.Nonce)
 }
 
+// Transaction is a transaction with what proves its sender agreed to it:
+// the sender's key and signature or, sent from a multisig address, the
+// policy of the address and a signature for each of its keys, nil for the
+// keys that did not sign.
 type Transaction struct {
-	SenderPublicKey *ecdsa.PublicKey
+	SenderPublicKey *PublicKey
 	Signature       *Signature
+	Multisig        *Multisig
+	Signatures      []*Signature
 	Tx              BlockTransaction
 }
 
+// MarshalJSON tags the transaction with the scheme of its key, which the
+// key and signature are read with.
 func (t *Transaction) MarshalJSON() ([]byte, error) {
+	scheme := ""
+	if t.SenderPublicKey != nil {
+		scheme = t.SenderPublicKey.Scheme.Name()
+	}
 	return json.Marshal(struct {
-		SenderPublicKey  *ecdsa.PublicKey `json:"sender_public_key"`
-		Signature        *Signature       `json:"signature"`
-		SenderAddress    string           `json:"sender_address"`
-		RecipientAddress string           `json:"recipient_address"`
-		Value            float32          `json:"value"`
+		Scheme           string       `json:"scheme,omitempty"`
+		SenderPublicKey  *PublicKey   `json:"sender_public_key"`
+		Signature        *Signature   `json:"signature"`
+		Multisig         *Multisig    `json:"multisig,omitempty"`
+		Signatures       []*Signature `json:"signatures,omitempty"`
+		SenderAddress    string       `json:"sender_address"`
+		RecipientAddress string       `json:"recipient_address"`
+		Value            float32      `json:"value"`
+		Fee              float32      `json:"fee"`
+		Nonce            uint64       `json:"nonce"`
 	}{
+		Scheme:           scheme,
 		SenderPublicKey:  t.SenderPublicKey,
 		Signature:        t.Signature,
+		Multisig:         t.Multisig,
+		Signatures:       t.Signatures,
 		SenderAddress:    t.Tx.SenderAddress,
 		RecipientAddress: t.Tx.RecipientAddress,
 		Value:            t.Tx.Value,
+		Fee:              t.Tx.Fee,
+		Nonce:            t.Tx.Nonce,
 	})
 }
 
 func (t *Transaction) UnmarshalJSON(mt []byte) error {
 	type ttt struct {
+		Scheme           string          `json:"scheme"`
 		SenderPublicKey  json.RawMessage `json:"sender_public_key"`
 		Signature        *Signature      `json:"signature"`
+		Multisig         *Multisig       `json:"multisig"`
+		Signatures       []*Signature    `json:"signatures"`
 		SenderAddress    string          `json:"sender_address"`
 		RecipientAddress string          `json:"recipient_address"`
 		Value            float32         `json:"value"`
+		Fee              float32         `json:"fee"`
+		Nonce            uint64          `json:"nonce"`
 	}
 	tt := new(ttt)
 	if err := json.Unmarshal(mt, &tt); err != nil {
 		return err
 	}
 
-	var spk *ecdsa.PublicKey
-	json.Unmarshal(tt.SenderPublicKey, &spk)
-	spk.Curve = elliptic.P256()
+	scheme, err := SchemeByName(tt.Scheme)
+	if err != nil {
+		return err
+	}
+	spk, err := PublicKeyFromJSON(scheme, tt.SenderPublicKey)
+	if err != nil {
+		return err
+	}
 
 	t.SenderPublicKey = spk
 	t.Signature = tt.Signature
+	t.Multisig = tt.Multisig
+	t.Signatures = tt.Signatures
 	t.Tx.SenderAddress = tt.SenderAddress
 	t.Tx.RecipientAddress = tt.RecipientAddress
 	t.Tx.Value = tt.Value
+	t.Tx.Fee = tt.Fee
+	t.Tx.Nonce = tt.Nonce
 
 	return nil
 }
 
+// TransactionRequest asks the wallet server to send value to a recipient.
+// It carries no key: the server builds and signs the transaction with the
+// unlocked wallet, from SenderBlockchainAddress if it has several.
 type TransactionRequest struct {
-	SenderPrivateKey           *string `json:"sender_private_key"`
 	SenderBlockchainAddress    *string `json:"sender_blockchain_address"`
 	RecipientBlockchainAddress *string `json:"recipient_blockchain_address"`
-	SenderPublicKey            *string `json:"sender_public_key"`
 	Value                      *string `json:"value"`
+	Fee                        *string `json:"fee"`
 }
 
-func (tr *TransactionRequest) Validate() bool {
-	if tr.SenderPrivateKey == nil ||
-		tr.SenderBlockchainAddress == nil ||
-		tr.RecipientBlockchainAddress == nil ||
-		tr.SenderPublicKey == nil ||
-		tr.Value == nil {
-		return false
+// Validate checks tr and rewrites its addresses, which may be typed in
+// Bech32, in the Base58Check form transactions carry.
+func (tr *TransactionRequest) Validate(network *Network) error {
+	if tr.RecipientBlockchainAddress == nil || tr.Value == nil {
+		return errors.New("missing field(s)")
+	}
+	recipient, err := network.ParseAddress(*tr.RecipientBlockchainAddress)
+	if err != nil {
+		return fmt.Errorf("recipient: %w", err)
 	}
-	return true
+	tr.RecipientBlockchainAddress = &recipient
+	if tr.SenderBlockchainAddress != nil && *tr.SenderBlockchainAddress != "" {
+		sender, err := network.ParseAddress(*tr.SenderBlockchainAddress)
+		if err != nil {
+			return fmt.Errorf("sender: %w", err)
+		}
+		tr.SenderBlockchainAddress = &sender
+	}
+	return nil
+}
+
+type FaucetRequest struct {
+	Address *string `json:"address"`
+}
+
+func (fr *FaucetRequest) Validate() bool {
+	return fr.Address != nil && *fr.Address != ""
+}
+
+type UnlockRequest struct {
+	Passphrase *string `json:"passphrase"`
+	Duration   *int    `json:"duration"`
+}
+
+func (ur *UnlockRequest) Validate() bool {
+	return ur.Passphrase != nil && (ur.Duration == nil || *ur.Duration >= 0)
+}
+
+type PassphraseRequest struct {
+	OldPassphrase *string `json:"old_passphrase"`
+	NewPassphrase *string `json:"new_passphrase"`
+}
+
+func (pr *PassphraseRequest) Validate() bool {
+	return pr.OldPassphrase != nil && pr.NewPassphrase != nil && *pr.NewPassphrase != ""
+}
+
+type HDCreateRequest struct {
+	Name               *string `json:"name"`
+	Passphrase         *string `json:"passphrase"`
+	MnemonicPassphrase *string `json:"mnemonic_passphrase"`
+	Words              *int    `json:"words"`
+}
+
+func (hr *HDCreateRequest) Validate() bool {
+	return hr.Passphrase != nil && *hr.Passphrase != "" &&
+		(hr.Words == nil || (*hr.Words >= 12 && *hr.Words <= 24 && *hr.Words%3 == 0))
+}
+
+type HDRestoreRequest struct {
+	Name               *string `json:"name"`
+	Mnemonic           *string `json:"mnemonic"`
+	Passphrase         *string `json:"passphrase"`
+	MnemonicPassphrase *string `json:"mnemonic_passphrase"`
+}
+
+func (hr *HDRestoreRequest) Validate() bool {
+	return hr.Mnemonic != nil && *hr.Mnemonic != "" && hr.Passphrase != nil && *hr.Passphrase != ""
+}
+
+type KeyRequest struct {
+	Name       *string `json:"name"`
+	Scheme     *string `json:"scheme"`
+	PrivateKey *string `json:"private_key"`
+	Passphrase *string `json:"passphrase"`
+}
+
+func (kr *KeyRequest) Validate() bool {
+	return kr.PrivateKey != nil && *kr.PrivateKey != ""
+}
+
+type KeyExportRequest struct {
+	Address    *string `json:"address"`
+	Password   *string `json:"password"`
+	Passphrase *string `json:"passphrase"`
+	Format     *string `json:"format"`
+}
+
+func (kr *KeyExportRequest) Validate() bool {
+	return kr.Password != nil && kr.Passphrase != nil && kr.Format != nil
+}
+
+type LoginRequest struct {
+	Name     *string `json:"name"`
+	Password *string `json:"password"`
+}
+
+func (lr *LoginRequest) Validate() bool {
+	return lr.Name != nil && lr.Password != nil
+}
+
+type WalletCreateRequest struct {
+	Name       *string `json:"name"`
+	Scheme     *string `json:"scheme"`
+	Passphrase *string `json:"passphrase"`
+}
+
+func (wr *WalletCreateRequest) Validate() bool {
+	return wr.Passphrase != nil && *wr.Passphrase != ""
+}
+
+type WalletSelectRequest struct {
+	Name *string `json:"name"`
+}
+
+func (wr *WalletSelectRequest) Validate() bool {
+	return wr.Name != nil && *wr.Name != ""
+}
+
+type WalletWatchRequest struct {
+	Name    *string `json:"name"`
+	Address *string `json:"address"`
+}
+
+func (wr *WalletWatchRequest) Validate() bool {
+	return wr.Address != nil && *wr.Address != ""
+}
+
+// MultisigCreateRequest asks for a wallet of the address of the policy of
+// M signatures out of keys.
+type MultisigCreateRequest struct {
+	Name *string       `json:"name"`
+	M    *int          `json:"m"`
+	Keys []MultisigKey `json:"keys"`
+}
+
+func (mr *MultisigCreateRequest) Validate() bool {
+	return mr.M != nil && len(mr.Keys) > 0
+}
+
+// MultisigSignRequest names the pending multisig transaction to co-sign.
+type MultisigSignRequest struct {
+	ID *string `json:"id"`
+}
+
+func (mr *MultisigSignRequest) Validate() bool {
+	return mr.ID != nil && *mr.ID != ""
+}
+
+// BroadcastRequest carries a transaction signed offline, as JSON or in the
+// base64 a signer writes.
+type BroadcastRequest struct {
+	Transaction *string `json:"transaction"`
+}
+
+func (br *BroadcastRequest) Validate() bool {
+	return br.Transaction != nil && *br.Transaction != ""
 }
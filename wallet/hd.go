@@ -0,0 +1,161 @@
+package wallet
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	. "goblockchain/common"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	HD_HARDENED  = 0x80000000
+	HD_PURPOSE   = 44
+	HD_GAP_LIMIT = 20
+
+	// SLIP-10 master key for the P-256 curve
+	HD_SEED_KEY = "Nist256p1 seed"
+)
+
+var ErrInvalidPath = errors.New("invalid derivation path")
+
+// ExtendedKey is a P-256 private key with the chain code needed to derive
+// its children. Derivation follows SLIP-10, which carries BIP-32 over to
+// curves other than secp256k1.
+type ExtendedKey struct {
+	key       []byte
+	chainCode []byte
+}
+
+// HDAccount is an address derived from a seed, at Path.
+type HDAccount struct {
+	Index   int    `json:"index"`
+	Path    string `json:"path"`
+	Address string `json:"address"`
+}
+
+func hmacSHA512(key []byte, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func ser32(i uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, i)
+	return b
+}
+
+func NewMasterKey(seed []byte) *ExtendedKey {
+	n := elliptic.P256().Params().N
+	i := hmacSHA512([]byte(HD_SEED_KEY), seed)
+	for {
+		k := new(big.Int).SetBytes(i[:32])
+		if k.Sign() != 0 && k.Cmp(n) < 0 {
+			return &ExtendedKey{key: i[:32], chainCode: i[32:]}
+		}
+		i = hmacSHA512([]byte(HD_SEED_KEY), i)
+	}
+}
+
+// Child derives the child key at index, hardened from HD_HARDENED on.
+func (k *ExtendedKey) Child(index uint32) *ExtendedKey {
+	curve := elliptic.P256()
+	n := curve.Params().N
+	var data []byte
+	if index >= HD_HARDENED {
+		data = append([]byte{0x00}, k.key...)
+	} else {
+		x, y := curve.ScalarBaseMult(k.key)
+		data = elliptic.MarshalCompressed(curve, x, y)
+	}
+	data = append(data, ser32(index)...)
+	for {
+		i := hmacSHA512(k.chainCode, data)
+		il := new(big.Int).SetBytes(i[:32])
+		child := new(big.Int).Add(il, new(big.Int).SetBytes(k.key))
+		child.Mod(child, n)
+		if il.Cmp(n) < 0 && child.Sign() != 0 {
+			return &ExtendedKey{key: child.FillBytes(make([]byte, 32)), chainCode: i[32:]}
+		}
+		data = append([]byte{0x01}, i[32:]...)
+		data = append(data, ser32(index)...)
+	}
+}
+
+// ParsePath reads a derivation path such as m/44'/1'/0'/0/5, where ' or h
+// marks hardened indexes.
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, ErrInvalidPath
+	}
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		var offset uint32
+		if strings.HasSuffix(p, "'") || strings.HasSuffix(p, "h") {
+			p = p[:len(p)-1]
+			offset = HD_HARDENED
+		}
+		i, err := strconv.ParseUint(p, 10, 32)
+		if err != nil || i >= HD_HARDENED {
+			return nil, ErrInvalidPath
+		}
+		indexes = append(indexes, uint32(i)+offset)
+	}
+	return indexes, nil
+}
+
+func (k *ExtendedKey) Derive(path string) (*ExtendedKey, error) {
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range indexes {
+		k = k.Child(i)
+	}
+	return k, nil
+}
+
+// AccountPath is the BIP-44 path of the index-th receiving address of the
+// first account: m/44'/coin'/0'/0/index.
+func AccountPath(network *Network, index int) string {
+	return fmt.Sprintf("m/%d'/%d'/0'/0/%d", HD_PURPOSE, network.HDCoinType, index)
+}
+
+// DeriveAccount derives the index-th address of master.
+func DeriveAccount(master *ExtendedKey, network *Network, index int) (*Wallet, *HDAccount) {
+	path := AccountPath(network, index)
+	k, _ := master.Derive(path)
+	w, _ := NewWalletFromBytes(P256, k.key, network)
+	return w, &HDAccount{Index: index, Path: path, Address: w.BlockchainAddress()}
+}
+
+// DiscoverAccounts derives addresses of master until HD_GAP_LIMIT
+// consecutive ones were never used, as reported by used, and returns every
+// address up to the last used one. The first address is always returned.
+func DiscoverAccounts(master *ExtendedKey, network *Network, used func(address string) (bool, error)) ([]HDAccount, error) {
+	accounts := make([]HDAccount, 0)
+	// the index of the last used address, -1 while none is
+	last := -1
+	for i := 0; i-last <= HD_GAP_LIMIT; i++ {
+		_, a := DeriveAccount(master, network, i)
+		ok, err := used(a.Address)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			last = i
+		}
+		accounts = append(accounts, *a)
+	}
+	if last < 0 {
+		return accounts[:1], nil
+	}
+	return accounts[:last+1], nil
+}
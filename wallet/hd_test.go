@@ -0,0 +1,133 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	. "goblockchain/common"
+	"reflect"
+	"testing"
+)
+
+func TestSLIP10Vectors(t *testing.T) {
+	// SLIP-10 test vectors for nist256p1
+	tests := []struct {
+		seed      string
+		path      string
+		chainCode string
+		key       string
+	}{
+		// vector 1
+		{"000102030405060708090a0b0c0d0e0f", "m",
+			"beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea", "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2"},
+		{"000102030405060708090a0b0c0d0e0f", "m/0'",
+			"3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11", "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c"},
+		{"000102030405060708090a0b0c0d0e0f", "m/0'/1",
+			"4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c", "284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129"},
+		{"000102030405060708090a0b0c0d0e0f", "m/0'/1/2'",
+			"98c7514f562e64e74170cc3cf304ee1ce54d6b6da4f880f313e8204c2a185318", "694596e8a54f252c960eb771a3c41e7e32496d03b954aeb90f61635b8e092aa7"},
+		{"000102030405060708090a0b0c0d0e0f", "m/0'/1/2'/2",
+			"ba96f776a5c3907d7fd48bde5620ee374d4acfd540378476019eab70790c63a0", "5996c37fd3dd2679039b23ed6f70b506c6b56b3cb5e424681fb0fa64caf82aaa"},
+		{"000102030405060708090a0b0c0d0e0f", "m/0'/1/2'/2/1000000000",
+			"b9b7b82d326bb9cb5b5b121066feea4eb93d5241103c9e7a18aad40f1dde8059", "21c4f269ef0a5fd1badf47eeacebeeaa3de22eb8e5b0adcd0f27dd99d34d0119"},
+		// vector 2
+		{"fffcf9f6f3f0edeae7e4e1dedbd8d5d2cfccc9c6c3c0bdbab7b4b1aeaba8a5a29f9c999693908d8a8784817e7b7875726f6c696663605d5a5754514e4b484542", "m",
+			"96cd4465a9644e31528eda3592aa35eb39a9527769ce1855beafc1b81055e75d", "eaa31c2e46ca2962227cf21d73a7ef0ce8b31c756897521eb6c7b39796633357"},
+		{"fffcf9f6f3f0edeae7e4e1dedbd8d5d2cfccc9c6c3c0bdbab7b4b1aeaba8a5a29f9c999693908d8a8784817e7b7875726f6c696663605d5a5754514e4b484542", "m/0/2147483647'/1/2147483646'/2",
+			"3bfb29ee8ac4484f09db09c2079b520ea5616df7820f071a20320366fbe226a7", "bb0a77ba01cc31d77205d51d08bd313b979a71ef4de9b062f8958297e746bd67"},
+		// derivation retry
+		{"000102030405060708090a0b0c0d0e0f", "m/28578'/33941",
+			"9e87fe95031f14736774cd82f25fd885065cb7c358c1edf813c72af535e83071", "092154eed4af83e078ff9b84322015aefe5769e31270f62c3f66c33888335f3a"},
+		// seed retry
+		{"a7305bc8df8d0951f0cb224c0e95d7707cbdf2c6ce7e8d481fec69c7ff5e9446", "m",
+			"7762f9729fed06121fd13f326884c82f59aa95c57ac492ce8c9654e60efd130c", "3b8c18469a4634517d6d0b65448f8e6c62091b45540a1743c5846be55d47d88f"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			seed, _ := hex.DecodeString(tt.seed)
+			k, err := NewMasterKey(seed).Derive(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(k.chainCode); got != tt.chainCode {
+				t.Errorf("chain code = %s, want %s", got, tt.chainCode)
+			}
+			if got := hex.EncodeToString(k.key); got != tt.key {
+				t.Errorf("key = %s, want %s", got, tt.key)
+			}
+		})
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		indexes []uint32
+	}{
+		{"m", []uint32{}},
+		{"m/44'/1h/0'/0/5", []uint32{44 + HD_HARDENED, 1 + HD_HARDENED, HD_HARDENED, 0, 5}},
+		{"m/2147483647'", []uint32{HD_HARDENED + HD_HARDENED - 1}},
+		{"", nil},
+		{"44'/0", nil},
+		{"m/", nil},
+		{"m/-1", nil},
+		{"m/2147483648", nil},
+		{"m/0''", nil},
+	}
+	for _, tt := range tests {
+		indexes, err := ParsePath(tt.path)
+		if tt.indexes == nil {
+			if !errors.Is(err, ErrInvalidPath) {
+				t.Errorf("ParsePath(%q) = %v, %v, want %v", tt.path, indexes, err, ErrInvalidPath)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(indexes, tt.indexes) {
+			t.Errorf("ParsePath(%q) = %v, %v, want %v", tt.path, indexes, err, tt.indexes)
+		}
+	}
+}
+
+func TestDiscoverAccounts(t *testing.T) {
+	master := NewMasterKey(make([]byte, 32))
+	tests := []struct {
+		name     string
+		used     []int
+		scanned  int
+		accounts int
+	}{
+		{"none used", nil, HD_GAP_LIMIT, 1},
+		{"first used", []int{0}, HD_GAP_LIMIT + 1, 1},
+		{"sixth used", []int{5}, HD_GAP_LIMIT + 6, 6},
+		{"last of the first gap", []int{HD_GAP_LIMIT - 1}, 2 * HD_GAP_LIMIT, HD_GAP_LIMIT},
+		{"past the first gap", []int{HD_GAP_LIMIT}, HD_GAP_LIMIT, 1},
+		{"gap bridged", []int{3, 3 + HD_GAP_LIMIT}, 4 + 2*HD_GAP_LIMIT, 4 + HD_GAP_LIMIT},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			used := make(map[string]bool)
+			for _, i := range tt.used {
+				_, a := DeriveAccount(master, REGTEST, i)
+				used[a.Address] = true
+			}
+			scanned := 0
+			accounts, err := DiscoverAccounts(master, REGTEST, func(address string) (bool, error) {
+				scanned++
+				return used[address], nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if scanned != tt.scanned {
+				t.Errorf("%d addresses scanned, want %d", scanned, tt.scanned)
+			}
+			if len(accounts) != tt.accounts {
+				t.Errorf("%d accounts, want %d", len(accounts), tt.accounts)
+			}
+			for i, a := range accounts {
+				if a.Index != i || a.Path != AccountPath(REGTEST, i) {
+					t.Errorf("account %d = %+v", i, a)
+				}
+			}
+		})
+	}
+}
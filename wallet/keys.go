@@ -0,0 +1,209 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/btcsuite/btcutil/base58"
+	. "goblockchain/common"
+	"math/big"
+	"strings"
+)
+
+const (
+	KEY_FORMAT_HEX = "hex"
+	KEY_FORMAT_WIF = "wif"
+	KEY_FORMAT_PEM = "pem"
+
+	PEM_PKCS8 = "PRIVATE KEY"
+	PEM_SEC1  = "EC PRIVATE KEY"
+
+	// suffix of a WIF payload whose public key is compressed
+	WIF_COMPRESSED = 0x01
+)
+
+var (
+	ErrInvalidKey       = errors.New("invalid private key")
+	ErrUnsupportedCurve = errors.New("private key is of an unsupported type")
+	ErrSchemeMismatch   = errors.New("private key is of another scheme")
+	ErrKeyFormat        = errors.New("unknown private key format")
+)
+
+// NewWalletFromPrivateKey rebuilds the wallet of an existing P-256 key,
+// recomputing its public key rather than trusting the one it carries.
+func NewWalletFromPrivateKey(privateKey *ecdsa.PrivateKey, network *Network) (*Wallet, error) {
+	if privateKey != nil && privateKey.Curve != nil && privateKey.Curve.Params().Name != elliptic.P256().Params().Name {
+		return nil, ErrUnsupportedCurve
+	}
+	if privateKey == nil || privateKey.D == nil || privateKey.D.BitLen() > 256 {
+		return nil, ErrInvalidKey
+	}
+	return NewWalletFromBytes(P256, privateKey.D.FillBytes(make([]byte, PRIVATE_KEY_SIZE)), network)
+}
+
+// NewWalletFromBytes rebuilds the wallet of the private key d of scheme.
+func NewWalletFromBytes(scheme SignatureScheme, d []byte, network *Network) (*Wallet, error) {
+	publicKey, err := scheme.PublicKey(d)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	w := new(Wallet)
+	w.privateKey = d
+	w.publicKey = publicKey
+	// 2-9. Derive the Base58Check address of the public key.
+	w.blockchainAddress = AddressFromPublicKey(publicKey, network.AddressVersion)
+	return w, nil
+}
+
+// NewWalletFromHex rebuilds a wallet of scheme from the raw hex of its
+// private key, as returned by PrivateKeyString.
+func NewWalletFromHex(s string, scheme SignatureScheme, network *Network) (*Wallet, error) {
+	d, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	return NewWalletFromBytes(scheme, d, network)
+}
+
+// NewWalletFromPEM rebuilds a wallet from a PEM block holding a PKCS#8
+// P-256 or Ed25519 private key, or a SEC 1 P-256 one.
+func NewWalletFromPEM(data []byte, network *Network) (*Wallet, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case PEM_PKCS8:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case PEM_SEC1:
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, ErrKeyFormat
+	}
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	switch privateKey := key.(type) {
+	case *ecdsa.PrivateKey:
+		return NewWalletFromPrivateKey(privateKey, network)
+	case ed25519.PrivateKey:
+		return NewWalletFromBytes(ED25519, privateKey.Seed(), network)
+	default:
+		return nil, ErrUnsupportedCurve
+	}
+}
+
+// NewWalletFromWIF rebuilds a wallet from a Wallet Import Format string:
+// Base58Check of the network's WIF version, the 32 byte private key and,
+// optionally, WIF_COMPRESSED. Keys of other schemes than P-256 end with
+// WIF_COMPRESSED and the tag of their scheme.
+func NewWalletFromWIF(s string, network *Network) (*Wallet, error) {
+	payload, version, err := base58.CheckDecode(s)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	if version != network.WIFVersion {
+		return nil, ErrKeystoreNetwork
+	}
+	scheme := P256
+	if len(payload) == 34 && payload[32] == WIF_COMPRESSED {
+		if scheme, err = SchemeByTag(payload[33]); err != nil || scheme == P256 {
+			return nil, ErrInvalidKey
+		}
+		payload = payload[:32]
+	}
+	if len(payload) == 33 && payload[32] == WIF_COMPRESSED {
+		payload = payload[:32]
+	}
+	if len(payload) != 32 {
+		return nil, ErrInvalidKey
+	}
+	return NewWalletFromBytes(scheme, payload, network)
+}
+
+// ParsePrivateKey rebuilds a wallet from a private key in any of the
+// formats ExportPrivateKey writes. Raw hex keys are of scheme, P-256 if
+// nil; WIF and PEM keys tell their own, which must be scheme if not nil.
+func ParsePrivateKey(s string, scheme SignatureScheme, network *Network) (*Wallet, error) {
+	s = strings.TrimSpace(s)
+	var w *Wallet
+	var err error
+	switch {
+	case strings.HasPrefix(s, "-----BEGIN"):
+		w, err = NewWalletFromPEM([]byte(s), network)
+	case len(s) == 64 && isHex(s):
+		if scheme == nil {
+			scheme = P256
+		}
+		w, err = NewWalletFromHex(s, scheme, network)
+	default:
+		w, err = NewWalletFromWIF(s, network)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if scheme != nil && w.Scheme() != scheme {
+		return nil, fmt.Errorf("%w: %s, not %s", ErrSchemeMismatch, w.Scheme().Name(), scheme.Name())
+	}
+	return w, nil
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// PrivateKeyPEM encodes the private key as a PKCS#8 PEM block. There is no
+// standard one for secp256k1 keys.
+func (w *Wallet) PrivateKeyPEM() (string, error) {
+	var key interface{}
+	switch w.Scheme() {
+	case P256:
+		privateKey := new(ecdsa.PrivateKey)
+		privateKey.Curve = elliptic.P256()
+		privateKey.D = new(big.Int).SetBytes(w.privateKey)
+		privateKey.X, privateKey.Y = privateKey.Curve.ScalarBaseMult(w.privateKey)
+		key = privateKey
+	case ED25519:
+		key = ed25519.NewKeyFromSeed(w.privateKey)
+	default:
+		return "", fmt.Errorf("%w: no PEM encoding for %s keys", ErrKeyFormat, w.Scheme().Name())
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: PEM_PKCS8, Bytes: der})), nil
+}
+
+// PrivateKeyWIF encodes the private key in Wallet Import Format for
+// network.
+func (w *Wallet) PrivateKeyWIF(network *Network) string {
+	payload := append(append([]byte{}, w.privateKey...), WIF_COMPRESSED)
+	if w.Scheme() != P256 {
+		payload = append(payload, w.Scheme().Tag())
+	}
+	return base58.CheckEncode(payload, network.WIFVersion)
+}
+
+// ExportPrivateKey encodes the private key in format, one of the
+// KEY_FORMAT_ constants.
+func (w *Wallet) ExportPrivateKey(format string, network *Network) (string, error) {
+	switch format {
+	case KEY_FORMAT_HEX:
+		return w.PrivateKeyString(), nil
+	case KEY_FORMAT_WIF:
+		return w.PrivateKeyWIF(network), nil
+	case KEY_FORMAT_PEM:
+		return w.PrivateKeyPEM()
+	default:
+		return "", ErrKeyFormat
+	}
+}
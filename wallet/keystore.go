@@ -0,0 +1,691 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tyler-smith/go-bip39"
+	. "goblockchain/common"
+	"golang.org/x/crypto/scrypt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	KEY_KIND_PLAIN    = ""
+	KEY_KIND_HD       = "hd"
+	KEY_KIND_WATCH    = "watch"
+	KEY_KIND_MULTISIG = "multisig"
+
+	KEYSTORE_VERSION = 3
+	KEYSTORE_CIPHER  = "aes-256-gcm"
+	KEYSTORE_KDF     = "scrypt"
+
+	// scrypt parameters of the "standard" Ethereum keystore
+	SCRYPT_N     = 1 << 18
+	SCRYPT_R     = 8
+	SCRYPT_P     = 1
+	SCRYPT_DKLEN = 32
+)
+
+var (
+	ErrNoKey            = errors.New("no key for address")
+	ErrWrongPassphrase  = errors.New("wrong passphrase")
+	ErrWalletLocked     = errors.New("wallet is locked")
+	ErrEmptyPassphrase  = errors.New("empty passphrase")
+	ErrKeystoreNetwork  = errors.New("key belongs to another network")
+	ErrUnsupportedCrypt = errors.New("unsupported keystore cipher or kdf")
+	ErrNotHD            = errors.New("not an HD wallet")
+	ErrInvalidMnemonic  = errors.New("invalid mnemonic")
+	ErrWatchOnly        = errors.New("watch-only wallet has no key")
+	ErrMultisigWallet   = errors.New("multisig wallet has no key, its cosigners sign")
+	ErrNotMultisig      = errors.New("not a multisig wallet")
+)
+
+// KeyFile is the JSON document a wallet is saved as, modelled on the
+// Ethereum keystore v3 format. AES-GCM authenticates the ciphertext, with
+// the address as additional data, so no separate MAC is stored.
+//
+// A plain key file holds one private key. An HD key file holds the seed of
+// a hierarchical deterministic wallet instead, is named after its first
+// address and lists the addresses derived so far in the clear, so they can
+// be shown while it is locked. A watch-only key file holds no key at all,
+// only an address whose transactions are signed elsewhere, and a multisig
+// key file only the policy of its address, whose transactions are signed
+// by its cosigners.
+type KeyFile struct {
+	Version   int         `json:"version"`
+	ID        string      `json:"id"`
+	Kind      string      `json:"kind,omitempty"`
+	Scheme    string      `json:"scheme,omitempty"`
+	Address   string      `json:"address"`
+	PublicKey string      `json:"public_key"`
+	Network   string      `json:"network"`
+	Accounts  []HDAccount `json:"accounts,omitempty"`
+	Multisig  *Multisig   `json:"multisig,omitempty"`
+	Crypto    CryptoJSON  `json:"crypto"`
+}
+
+type CryptoJSON struct {
+	Cipher       string       `json:"cipher"`
+	CipherText   string       `json:"ciphertext"`
+	CipherParams CipherParams `json:"cipherparams"`
+	KDF          string       `json:"kdf"`
+	KDFParams    ScryptParams `json:"kdfparams"`
+}
+
+type CipherParams struct {
+	Nonce string `json:"nonce"`
+}
+
+type ScryptParams struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func newUUID() string {
+	b := randomBytes(16)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func sealer(passphrase string, params ScryptParams) (cipher.AEAD, error) {
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, params.DKLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(plaintext []byte, address string, passphrase string) (*CryptoJSON, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+	params := ScryptParams{N: SCRYPT_N, R: SCRYPT_R, P: SCRYPT_P, DKLen: SCRYPT_DKLEN, Salt: hex.EncodeToString(randomBytes(32))}
+	aead, err := sealer(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	nonce := randomBytes(aead.NonceSize())
+	return &CryptoJSON{
+		Cipher:       KEYSTORE_CIPHER,
+		CipherText:   hex.EncodeToString(aead.Seal(nil, nonce, plaintext, []byte(address))),
+		CipherParams: CipherParams{Nonce: hex.EncodeToString(nonce)},
+		KDF:          KEYSTORE_KDF,
+		KDFParams:    params,
+	}, nil
+}
+
+func open(kf *KeyFile, passphrase string, network *Network) ([]byte, error) {
+	if kf.Version != KEYSTORE_VERSION || kf.Crypto.Cipher != KEYSTORE_CIPHER || kf.Crypto.KDF != KEYSTORE_KDF {
+		return nil, ErrUnsupportedCrypt
+	}
+	if kf.Network != network.Name {
+		return nil, ErrKeystoreNetwork
+	}
+	aead, err := sealer(passphrase, kf.Crypto.KDFParams)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(kf.Crypto.CipherParams.Nonce)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce")
+	}
+	ciphertext, err := hex.DecodeString(kf.Crypto.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext")
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(kf.Address))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+// EncryptWallet seals the private key of w with a key derived from
+// passphrase.
+func EncryptWallet(w *Wallet, passphrase string, network *Network) (*KeyFile, error) {
+	c, err := seal(w.privateKey, w.blockchainAddress, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyFile{
+		Version:   KEYSTORE_VERSION,
+		ID:        newUUID(),
+		Kind:      KEY_KIND_PLAIN,
+		Scheme:    w.Scheme().Name(),
+		Address:   w.blockchainAddress,
+		PublicKey: w.PublicKeyString(),
+		Network:   network.Name,
+		Crypto:    *c,
+	}, nil
+}
+
+// DecryptWallet opens kf with passphrase and checks the key it holds
+// matches its address.
+func DecryptWallet(kf *KeyFile, passphrase string, network *Network) (*Wallet, error) {
+	if kf.Kind != KEY_KIND_PLAIN {
+		return nil, ErrUnsupportedCrypt
+	}
+	scheme, err := SchemeByName(kf.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	d, err := open(kf, passphrase, network)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWalletFromBytes(scheme, d, network)
+	if err != nil {
+		return nil, err
+	}
+	if w.blockchainAddress != kf.Address {
+		return nil, fmt.Errorf("key does not match address %s", kf.Address)
+	}
+	return w, nil
+}
+
+// EncryptSeed seals the seed of an HD wallet whose derived addresses are
+// accounts.
+func EncryptSeed(seed []byte, accounts []HDAccount, passphrase string, network *Network) (*KeyFile, error) {
+	first, a := DeriveAccount(NewMasterKey(seed), network, 0)
+	c, err := seal(seed, a.Address, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyFile{
+		Version:   KEYSTORE_VERSION,
+		ID:        newUUID(),
+		Kind:      KEY_KIND_HD,
+		Scheme:    first.Scheme().Name(),
+		Address:   a.Address,
+		PublicKey: first.PublicKeyString(),
+		Network:   network.Name,
+		Accounts:  accounts,
+		Crypto:    *c,
+	}, nil
+}
+
+// DecryptSeed opens the HD key file kf and returns its master key.
+func DecryptSeed(kf *KeyFile, passphrase string, network *Network) (*ExtendedKey, error) {
+	if kf.Kind != KEY_KIND_HD {
+		return nil, ErrNotHD
+	}
+	seed, err := open(kf, passphrase, network)
+	if err != nil {
+		return nil, err
+	}
+	master := NewMasterKey(seed)
+	if _, a := DeriveAccount(master, network, 0); a.Address != kf.Address {
+		return nil, fmt.Errorf("seed does not match address %s", kf.Address)
+	}
+	return master, nil
+}
+
+// Keystore keeps wallets encrypted on disk, one <address>.json file each,
+// and the wallets that were unlocked in memory until they are locked again.
+// Every address derived from an unlocked HD key file is unlocked with it.
+type Keystore struct {
+	dir      string
+	network  *Network
+	unlocked map[string]*Wallet
+	owners   map[string]string
+	masters  map[string]*ExtendedKey
+	timers   map[string]*time.Timer
+	mux      sync.Mutex
+}
+
+func NewKeystore(dir string, network *Network) (*Keystore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Keystore{
+		dir:      dir,
+		network:  network,
+		unlocked: make(map[string]*Wallet),
+		owners:   make(map[string]string),
+		masters:  make(map[string]*ExtendedKey),
+		timers:   make(map[string]*time.Timer),
+	}, nil
+}
+
+func (ks *Keystore) path(address string) string {
+	return filepath.Join(ks.dir, address+".json")
+}
+
+// Addresses lists the addresses of the key files in the keystore. The
+// other addresses of an HD key file are listed by Accounts.
+func (ks *Keystore) Addresses() ([]string, error) {
+	entries, err := os.ReadDir(ks.dir)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, 0)
+	for _, e := range entries {
+		if name := e.Name(); !e.IsDir() && strings.HasSuffix(name, ".json") {
+			addresses = append(addresses, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+// Load reads the key file of address without decrypting it.
+func (ks *Keystore) Load(address string) (*KeyFile, error) {
+	data, err := os.ReadFile(ks.path(address))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	kf := new(KeyFile)
+	if err := json.Unmarshal(data, kf); err != nil {
+		return nil, fmt.Errorf("%s: %v", ks.path(address), err)
+	}
+	return kf, nil
+}
+
+func (ks *Keystore) save(kf *KeyFile) error {
+	data, _ := json.MarshalIndent(kf, "", "  ")
+	tmp := ks.path(kf.Address) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ks.path(kf.Address))
+}
+
+// Store encrypts w with passphrase and saves it, replacing any previous
+// file for its address.
+func (ks *Keystore) Store(w *Wallet, passphrase string) error {
+	kf, err := EncryptWallet(w, passphrase, ks.network)
+	if err != nil {
+		return err
+	}
+	return ks.save(kf)
+}
+
+// Create generates a new wallet with a key of scheme and stores it
+// encrypted with passphrase.
+func (ks *Keystore) Create(scheme SignatureScheme, passphrase string) (*Wallet, error) {
+	w, err := NewSchemeWallet(scheme, ks.network)
+	if err != nil {
+		return nil, err
+	}
+	if err := ks.Store(w, passphrase); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// CreateHD generates a BIP-39 mnemonic of bits bits of entropy and stores
+// the HD wallet it seeds, with its first address, encrypted with
+// passphrase. The mnemonic, protected by mnemonicPassphrase if not empty,
+// is the only backup of the wallet and is not kept anywhere.
+func (ks *Keystore) CreateHD(passphrase string, mnemonicPassphrase string, bits int) (string, *KeyFile, error) {
+	entropy, err := bip39.NewEntropy(bits)
+	if err != nil {
+		return "", nil, err
+	}
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return "", nil, err
+	}
+	seed := bip39.NewSeed(mnemonic, mnemonicPassphrase)
+	_, a := DeriveAccount(NewMasterKey(seed), ks.network, 0)
+	kf, err := EncryptSeed(seed, []HDAccount{*a}, passphrase, ks.network)
+	if err != nil {
+		return "", nil, err
+	}
+	return mnemonic, kf, ks.save(kf)
+}
+
+// Watch stores a watch-only key file for address, in Base58Check or
+// Bech32 form, which a signer holds the key of.
+func (ks *Keystore) Watch(address string) (*KeyFile, error) {
+	address, err := ks.network.ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if kf, err := ks.Load(address); err == nil {
+		return kf, nil
+	}
+	kf := &KeyFile{
+		Version: KEYSTORE_VERSION,
+		ID:      newUUID(),
+		Kind:    KEY_KIND_WATCH,
+		Address: address,
+		Network: ks.network.Name,
+	}
+	return kf, ks.save(kf)
+}
+
+// WatchOnly tells whether the key file of address is watch-only.
+func (ks *Keystore) WatchOnly(address string) bool {
+	kf, err := ks.Load(address)
+	return err == nil && kf.Kind == KEY_KIND_WATCH
+}
+
+// AddMultisig stores a multisig key file for the address of ms.
+func (ks *Keystore) AddMultisig(ms *Multisig) (*KeyFile, error) {
+	address := ms.Address(ks.network)
+	if kf, err := ks.Load(address); err == nil {
+		return kf, nil
+	}
+	kf := &KeyFile{
+		Version:  KEYSTORE_VERSION,
+		ID:       newUUID(),
+		Kind:     KEY_KIND_MULTISIG,
+		Address:  address,
+		Network:  ks.network.Name,
+		Multisig: ms,
+	}
+	return kf, ks.save(kf)
+}
+
+// IsMultisig tells whether the key file of address is multisig.
+func (ks *Keystore) IsMultisig(address string) bool {
+	kf, err := ks.Load(address)
+	return err == nil && kf.Kind == KEY_KIND_MULTISIG
+}
+
+// Multisig returns the policy of the multisig key file of address, or
+// ErrNotMultisig.
+func (ks *Keystore) Multisig(address string) (*Multisig, error) {
+	kf, err := ks.Load(address)
+	if err != nil {
+		return nil, err
+	}
+	if kf.Kind != KEY_KIND_MULTISIG || kf.Multisig == nil {
+		return nil, ErrNotMultisig
+	}
+	return kf.Multisig, nil
+}
+
+// keyless tells why kf holds no key to decrypt, if it is watch-only or
+// multisig.
+func keyless(kf *KeyFile) error {
+	switch kf.Kind {
+	case KEY_KIND_WATCH:
+		return ErrWatchOnly
+	case KEY_KIND_MULTISIG:
+		return ErrMultisigWallet
+	}
+	return nil
+}
+
+// Keyless tells whether the key file of address holds no key, being
+// watch-only or multisig.
+func (ks *Keystore) Keyless(address string) bool {
+	kf, err := ks.Load(address)
+	return err == nil && keyless(kf) != nil
+}
+
+// Entry finds the key file holding the key of address, which may be any
+// address of an HD wallet. Watch-only and multisig key files are skipped.
+func (ks *Keystore) Entry(address string) (string, error) {
+	entries, err := ks.Addresses()
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		kf, err := ks.Load(entry)
+		if err != nil || keyless(kf) != nil {
+			continue
+		}
+		if kf.Address == address {
+			return entry, nil
+		}
+		for _, account := range kf.Accounts {
+			if account.Address == address {
+				return entry, nil
+			}
+		}
+	}
+	return "", ErrNoKey
+}
+
+// RestoreHD recovers the HD wallet of mnemonic, with every address up to
+// the last one used, as reported by used (see DiscoverAccounts), and
+// stores it encrypted with passphrase.
+func (ks *Keystore) RestoreHD(mnemonic string, mnemonicPassphrase string, passphrase string, used func(address string) (bool, error)) (*KeyFile, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, ErrInvalidMnemonic
+	}
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+	seed := bip39.NewSeed(mnemonic, mnemonicPassphrase)
+	accounts, err := DiscoverAccounts(NewMasterKey(seed), ks.network, used)
+	if err != nil {
+		return nil, err
+	}
+	// addresses handed out but not used yet would be lost otherwise
+	if kf, err := ks.Load(accounts[0].Address); err == nil && len(kf.Accounts) > len(accounts) {
+		accounts = kf.Accounts
+	}
+	kf, err := EncryptSeed(seed, accounts, passphrase, ks.network)
+	if err != nil {
+		return nil, err
+	}
+	ks.Lock(kf.Address)
+	return kf, ks.save(kf)
+}
+
+// Accounts lists the addresses of the key file of address: the ones
+// derived so far for an HD wallet, address alone otherwise.
+func (ks *Keystore) Accounts(address string) ([]HDAccount, error) {
+	kf, err := ks.Load(address)
+	if err != nil {
+		return nil, err
+	}
+	if kf.Kind != KEY_KIND_HD {
+		return []HDAccount{{Address: kf.Address}}, nil
+	}
+	return kf.Accounts, nil
+}
+
+// NewAccount derives the next address of the unlocked HD wallet of address
+// and records it in its key file.
+func (ks *Keystore) NewAccount(address string) (*HDAccount, error) {
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+	kf, err := ks.Load(address)
+	if err != nil {
+		return nil, err
+	}
+	if kf.Kind != KEY_KIND_HD {
+		return nil, ErrNotHD
+	}
+	master, ok := ks.masters[address]
+	if !ok {
+		return nil, ErrWalletLocked
+	}
+	w, a := DeriveAccount(master, ks.network, len(kf.Accounts))
+	kf.Accounts = append(kf.Accounts, *a)
+	if err := ks.save(kf); err != nil {
+		return nil, err
+	}
+	ks.unlocked[a.Address] = w
+	ks.owners[a.Address] = address
+	return a, nil
+}
+
+// Unlock decrypts the key file of address and keeps its wallets available
+// for signing. A positive duration locks them again once elapsed. The
+// wallet of address itself is returned.
+func (ks *Keystore) Unlock(address string, passphrase string, duration time.Duration) (*Wallet, error) {
+	kf, err := ks.Load(address)
+	if err != nil {
+		return nil, err
+	}
+	wallets := make(map[string]*Wallet)
+	var master *ExtendedKey
+	if err := keyless(kf); err != nil {
+		return nil, err
+	}
+	if kf.Kind == KEY_KIND_HD {
+		if master, err = DecryptSeed(kf, passphrase, ks.network); err != nil {
+			return nil, err
+		}
+		for _, account := range kf.Accounts {
+			w, a := DeriveAccount(master, ks.network, account.Index)
+			if a.Address != account.Address {
+				return nil, fmt.Errorf("seed does not match address %s", account.Address)
+			}
+			wallets[a.Address] = w
+		}
+	} else {
+		w, err := DecryptWallet(kf, passphrase, ks.network)
+		if err != nil {
+			return nil, err
+		}
+		wallets[address] = w
+	}
+
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+	ks.lock(address)
+	for a, w := range wallets {
+		ks.unlocked[a] = w
+		ks.owners[a] = address
+	}
+	if master != nil {
+		ks.masters[address] = master
+	}
+	if duration > 0 {
+		ks.timers[address] = time.AfterFunc(duration, func() { ks.Lock(address) })
+	}
+	return ks.unlocked[address], nil
+}
+
+// Lock forgets the decrypted wallets of the key file of address.
+func (ks *Keystore) Lock(address string) {
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+	ks.lock(address)
+}
+
+// LockAll forgets every decrypted wallet.
+func (ks *Keystore) LockAll() {
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+	for address := range ks.masters {
+		ks.lock(address)
+	}
+	for _, owner := range ks.owners {
+		ks.lock(owner)
+	}
+}
+
+func (ks *Keystore) lock(address string) {
+	for a, owner := range ks.owners {
+		if owner == address {
+			delete(ks.unlocked, a)
+			delete(ks.owners, a)
+		}
+	}
+	delete(ks.masters, address)
+	if t, ok := ks.timers[address]; ok {
+		t.Stop()
+		delete(ks.timers, address)
+	}
+}
+
+// Wallet returns the unlocked wallet of address, or ErrWalletLocked.
+// address may be any address of an HD wallet.
+func (ks *Keystore) Wallet(address string) (*Wallet, error) {
+	ks.mux.Lock()
+	defer ks.mux.Unlock()
+	if w, ok := ks.unlocked[address]; ok {
+		return w, nil
+	}
+	return nil, ErrWalletLocked
+}
+
+// Decrypt opens the key file of entry with passphrase and returns the
+// wallet of address, one of its addresses, without unlocking it.
+func (ks *Keystore) Decrypt(entry string, address string, passphrase string) (*Wallet, error) {
+	kf, err := ks.Load(entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := keyless(kf); err != nil {
+		return nil, err
+	}
+	if kf.Kind != KEY_KIND_HD {
+		if address != kf.Address {
+			return nil, ErrNoKey
+		}
+		return DecryptWallet(kf, passphrase, ks.network)
+	}
+	for _, account := range kf.Accounts {
+		if account.Address == address {
+			master, err := DecryptSeed(kf, passphrase, ks.network)
+			if err != nil {
+				return nil, err
+			}
+			w, _ := DeriveAccount(master, ks.network, account.Index)
+			return w, nil
+		}
+	}
+	return nil, ErrNoKey
+}
+
+// ChangePassphrase re-encrypts the key file of address under a new
+// passphrase. It leaves the wallet locked or unlocked as it was.
+func (ks *Keystore) ChangePassphrase(address string, oldPassphrase string, newPassphrase string) error {
+	kf, err := ks.Load(address)
+	if err != nil {
+		return err
+	}
+	if err := keyless(kf); err != nil {
+		return err
+	}
+	if kf.Kind == KEY_KIND_HD {
+		seed, err := open(kf, oldPassphrase, ks.network)
+		if err != nil {
+			return err
+		}
+		c, err := seal(seed, kf.Address, newPassphrase)
+		if err != nil {
+			return err
+		}
+		kf.Crypto = *c
+		return ks.save(kf)
+	}
+	w, err := DecryptWallet(kf, oldPassphrase, ks.network)
+	if err != nil {
+		return err
+	}
+	return ks.Store(w, newPassphrase)
+}
@@ -0,0 +1,107 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	. "goblockchain/common"
+	"testing"
+)
+
+func TestEncryptWallet(t *testing.T) {
+	for _, scheme := range SIGNATURE_SCHEMES {
+		t.Run(scheme.Name(), func(t *testing.T) {
+			w, err := NewSchemeWallet(scheme, REGTEST)
+			if err != nil {
+				t.Fatal(err)
+			}
+			kf, err := EncryptWallet(w, "passphrase", REGTEST)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decrypted, err := DecryptWallet(kf, "passphrase", REGTEST)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decrypted.BlockchainAddress() != w.BlockchainAddress() || decrypted.PrivateKeyString() != w.PrivateKeyString() {
+				t.Errorf("DecryptWallet() = %s, want %s", decrypted.BlockchainAddress(), w.BlockchainAddress())
+			}
+		})
+	}
+
+	w := NewWallet(REGTEST)
+	if _, err := EncryptWallet(w, "", REGTEST); !errors.Is(err, ErrEmptyPassphrase) {
+		t.Errorf("EncryptWallet() with no passphrase = %v, want %v", err, ErrEmptyPassphrase)
+	}
+	kf, err := EncryptWallet(w, "passphrase", REGTEST)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptWallet(kf, "wrong", REGTEST); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("DecryptWallet() with a wrong passphrase = %v, want %v", err, ErrWrongPassphrase)
+	}
+	if _, err := DecryptWallet(kf, "passphrase", TESTNET); !errors.Is(err, ErrKeystoreNetwork) {
+		t.Errorf("DecryptWallet() on another network = %v, want %v", err, ErrKeystoreNetwork)
+	}
+	// the address is authenticated along with the key
+	kf.Address = NewWallet(REGTEST).BlockchainAddress()
+	if _, err := DecryptWallet(kf, "passphrase", REGTEST); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("DecryptWallet() of another address = %v, want %v", err, ErrWrongPassphrase)
+	}
+}
+
+func TestRestoreHD(t *testing.T) {
+	// BIP-39 test vectors, with the passphrase TREZOR
+	tests := []struct {
+		mnemonic string
+		seed     string
+	}{
+		{"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"},
+		{"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607"},
+		{"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+			"d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8"},
+		{"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069"},
+	}
+	unused := func(address string) (bool, error) { return false, nil }
+	for _, tt := range tests {
+		t.Run(tt.mnemonic, func(t *testing.T) {
+			ks, err := NewKeystore(t.TempDir(), REGTEST)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// spaces around and between the words do not matter
+			kf, err := ks.RestoreHD(" "+tt.mnemonic+"\n", "TREZOR", "passphrase", unused)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seed, _ := hex.DecodeString(tt.seed)
+			if _, a := DeriveAccount(NewMasterKey(seed), REGTEST, 0); kf.Address != a.Address {
+				t.Errorf("address = %s, want %s", kf.Address, a.Address)
+			}
+		})
+	}
+
+	ks, err := NewKeystore(t.TempDir(), REGTEST)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"
+	if _, err := ks.RestoreHD(checksum, "", "passphrase", unused); !errors.Is(err, ErrInvalidMnemonic) {
+		t.Errorf("RestoreHD() of a wrong checksum = %v, want %v", err, ErrInvalidMnemonic)
+	}
+	kf, err := ks.RestoreHD(tests[0].mnemonic, "", "passphrase", unused)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptSeed(kf, "passphrase", REGTEST); err != nil {
+		t.Errorf("DecryptSeed() = %v", err)
+	}
+	if _, err := DecryptSeed(kf, "wrong", REGTEST); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("DecryptSeed() with a wrong passphrase = %v, want %v", err, ErrWrongPassphrase)
+	}
+	if _, err := ks.RestoreHD(tests[0].mnemonic, "", "", unused); !errors.Is(err, ErrEmptyPassphrase) {
+		t.Errorf("RestoreHD() with no passphrase = %v, want %v", err, ErrEmptyPassphrase)
+	}
+}
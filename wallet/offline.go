@@ -0,0 +1,24 @@
+package wallet
+
+import (
+	"errors"
+	. "goblockchain/common"
+)
+
+var ErrNotSender = errors.New("wallet is not the sender of the transaction")
+
+// SignUnsignedTransaction signs a transaction built by a watch-only wallet
+// of w, after checking it was built for network and is what it claims.
+func (w *Wallet) SignUnsignedTransaction(u *UnsignedTransaction, network *Network) (*Transaction, error) {
+	if err := u.Check(network); err != nil {
+		return nil, err
+	}
+	if u.SenderAddress != w.blockchainAddress {
+		return nil, ErrNotSender
+	}
+	t := w.CreateTransaction(u.RecipientAddress, u.Value, u.Fee, u.Nonce)
+	if err := w.SignTransaction(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
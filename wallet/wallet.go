@@ -1,76 +1,53 @@
 package wallet
 
 import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"fmt"
-	"github.com/btcsuite/btcutil/base58"
+	"errors"
 	. "goblockchain/common"
-	"golang.org/x/crypto/ripemd160"
 )
 
+var ErrNotCosigner = errors.New("key is not one of the multisig policy")
+
 type Wallet struct {
-	privateKey        *ecdsa.PrivateKey
-	publicKey         *ecdsa.PublicKey
+	privateKey        []byte
+	publicKey         *PublicKey
 	blockchainAddress string
 }
 
-func NewWallet() *Wallet {
-	// 1. Creating ECDSA private key (32 bytes) public key (64 bytes)
-	w := new(Wallet)
-	privateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	w.privateKey = privateKey
-	w.publicKey = &w.privateKey.PublicKey
-	// 2. Perform SHA-256 hashing on the public key (32 bytes).
-	h2 := sha256.New()
-	h2.Write(w.publicKey.X.Bytes())
-	h2.Write(w.publicKey.Y.Bytes())
-	digest2 := h2.Sum(nil)
-	// 3. Perform RIPEMD-160 hashing on the result of SHA-256 (20 bytes).
-	h3 := ripemd160.New()
-	h3.Write(digest2)
-	digest3 := h3.Sum(nil)
-	// 4. Add version byte in front of RIPEMD-160 hash (0x00 for Main Network).
-	vd4 := make([]byte, 21)
-	vd4[0] = 0x00
-	copy(vd4[1:], digest3[:])
-	// 5. Perform SHA-256 hash on the extended RIPEMD-160 result.
-	h5 := sha256.New()
-	h5.Write(vd4)
-	digest5 := h5.Sum(nil)
-	// 6. Perform SHA-256 hash on the result of the previous SHA-256 hash.
-	h6 := sha256.New()
-	h6.Write(digest5)
-	digest6 := h6.Sum(nil)
-	// 7. Take the first 4 bytes of the second SHA-256 hash for checksum.
-	chsum := digest6[:4]
-	// 8. Add the 4 checksum bytes from 7 at the end of extended RIPEMD-160 hash from 4 (25 bytes).
-	dc8 := make([]byte, 25)
-	copy(dc8[:21], vd4[:])
-	copy(dc8[21:], chsum[:])
-	// 9. Convert the result from a byte string into base58.
-	address := base58.Encode(dc8)
-	w.blockchainAddress = address
+func NewWallet(network *Network) *Wallet {
+	w, _ := NewSchemeWallet(P256, network)
 	return w
 }
 
-func (w *Wallet) PrivateKey() *ecdsa.PrivateKey {
+// NewSchemeWallet creates a wallet with a new key of scheme.
+func NewSchemeWallet(scheme SignatureScheme, network *Network) (*Wallet, error) {
+	// 1. Creating the private key (32 bytes) and its public key
+	d, err := scheme.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	return NewWalletFromBytes(scheme, d, network)
+}
+
+func (w *Wallet) Scheme() SignatureScheme {
+	return w.publicKey.Scheme
+}
+
+func (w *Wallet) PrivateKey() []byte {
 	return w.privateKey
 }
 
 func (w *Wallet) PrivateKeyString() string {
-	return fmt.Sprintf("%x", w.privateKey.D.Bytes())
+	return hex.EncodeToString(w.privateKey)
 }
 
-func (w *Wallet) PublicKey() *ecdsa.PublicKey {
+func (w *Wallet) PublicKey() *PublicKey {
 	return w.publicKey
 }
 
 func (w *Wallet) PublicKeyString() string {
-	return fmt.Sprintf("%x%x", w.publicKey.X.Bytes(), w.publicKey.Y.Bytes())
+	return w.publicKey.String()
 }
 
 func (w *Wallet) BlockchainAddress() string {
@@ -79,31 +56,63 @@ func (w *Wallet) BlockchainAddress() string {
 
 func (w *Wallet) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
+		Scheme            string `json:"scheme"`
 		PrivateKey        string `json:"private_key"`
 		PublicKey         string `json:"public_key"`
 		BlockchainAddress string `json:"blockchain_address"`
 	}{
+		Scheme:            w.Scheme().Name(),
 		PrivateKey:        w.PrivateKeyString(),
 		PublicKey:         w.PublicKeyString(),
 		BlockchainAddress: w.BlockchainAddress(),
 	})
 }
 
-func (w *Wallet) CreateTransaction(recipient string, value float32) *Transaction {
+// CreateTransaction builds the transaction of w sending value to
+// recipient. nonce is the one the chain expects next from w.
+func (w *Wallet) CreateTransaction(recipient string, value float32, fee float32, nonce uint64) *Transaction {
 	t := new(Transaction) // new() return a pointer
 	t.SenderPublicKey = w.publicKey
 	t.Tx.SenderAddress = w.blockchainAddress
 	t.Tx.RecipientAddress = recipient
 	t.Tx.Value = value
+	t.Tx.Fee = fee
+	t.Tx.Nonce = nonce
 	return t
 }
 
-func (w *Wallet) SignTransaction(t *Transaction) {
-	tx := t.Tx
-	m, _ := json.Marshal(tx)
-	h := sha256.Sum256([]byte(m))
-	r, s, _ := ecdsa.Sign(rand.Reader, w.privateKey, h[:])
-	t.Signature = &Signature{R: r, S: s}
+// SignTransaction signs t with the scheme of w, in the canonical form the
+// chain accepts: low S on the ECDSA curves.
+func (w *Wallet) SignTransaction(t *Transaction) error {
+	h := TransactionSigningHash(&t.Tx)
+	sig, err := w.publicKey.Scheme.Sign(w.privateKey, h[:])
+	if err != nil {
+		return err
+	}
+	t.Signature = sig
+	return nil
+}
+
+// CoSign adds the signature of w to the multisig transaction t, in the
+// place of the key of w in its policy.
+func (w *Wallet) CoSign(t *Transaction) error {
+	if t.Multisig == nil {
+		return ErrNotCosigner
+	}
+	i := t.Multisig.IndexOf(w.publicKey)
+	if i < 0 {
+		return ErrNotCosigner
+	}
+	if len(t.Signatures) != len(t.Multisig.Keys) {
+		t.Signatures = make([]*Signature, len(t.Multisig.Keys))
+	}
+	h := TransactionSigningHash(&t.Tx)
+	sig, err := w.publicKey.Scheme.Sign(w.privateKey, h[:])
+	if err != nil {
+		return err
+	}
+	t.Signatures[i] = sig
+	return nil
 }
 
 //SHOULD USE THE ASN1 BELOW?
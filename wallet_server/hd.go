@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goblockchain/common"
+	"goblockchain/wallet"
+	"io"
+	"log"
+	"net/http"
+)
+
+const HD_DEFAULT_WORDS = 12
+
+var errGateway = errors.New("gateway unreachable")
+
+type hdWallet struct {
+	Name     string             `json:"name"`
+	Mnemonic string             `json:"mnemonic,omitempty"`
+	Address  string             `json:"address"`
+	Accounts []wallet.HDAccount `json:"accounts"`
+}
+
+// used tells whether the gateway's chain holds a confirmed transaction of
+// address, for gap-limit discovery.
+func (ws *WalletServer) used(address string) (bool, error) {
+	response, err := http.Get(ws.Gateway() + common.API_PREFIX + "/addresses/" + address + "/transactions?limit=1")
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", errGateway, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("%w: status %d", errGateway, response.StatusCode)
+	}
+	var page struct {
+		Total int `json:"total"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&page); err != nil {
+		return false, fmt.Errorf("%w: %v", errGateway, err)
+	}
+	return page.Total > 0, nil
+}
+
+// serve adds the HD key file of kf, unlocked with passphrase, to the
+// wallets of the user of s as name and selects it.
+func (ws *WalletServer) serve(res http.ResponseWriter, s *session, name *string, kf *wallet.KeyFile, passphrase string, mnemonic string) {
+	n := ""
+	if name != nil {
+		n = *name
+	}
+	w, err := ws.addWallet(s, n, kf.Address, passphrase)
+	if err != nil {
+		ws.userError(res, err)
+		return
+	}
+	res.Header().Add("Content-Type", "application/json")
+	res.WriteHeader(http.StatusCreated)
+	m, _ := json.Marshal(hdWallet{Name: w.Name, Mnemonic: mnemonic, Address: kf.Address, Accounts: kf.Accounts})
+	io.WriteString(res, string(m[:]))
+}
+
+// HDCreate generates a mnemonic and adds the HD wallet it seeds to the
+// user's. The mnemonic is returned once and never stored.
+func (ws *WalletServer) HDCreate(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		s := ws.session(res, req)
+		if s == nil {
+			return
+		}
+		decoder := json.NewDecoder(req.Body)
+		var hr common.HDCreateRequest
+		if err := decoder.Decode(&hr); err != nil || !hr.Validate() {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing passphrase or invalid number of words")
+			return
+		}
+		words, mnemonicPassphrase := HD_DEFAULT_WORDS, ""
+		if hr.Words != nil {
+			words = *hr.Words
+		}
+		if hr.MnemonicPassphrase != nil {
+			mnemonicPassphrase = *hr.MnemonicPassphrase
+		}
+		// every 3 words encode 32 bits of entropy and 1 of checksum
+		mnemonic, kf, err := s.keystore.CreateHD(*hr.Passphrase, mnemonicPassphrase, words/3*32)
+		if err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		ws.serve(res, s, hr.Name, kf, *hr.Passphrase, mnemonic)
+		ws.fund(kf.Address)
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
+
+// HDRestore recovers the HD wallet of a mnemonic, with the addresses the
+// gateway's chain shows were used, and adds it to the user's.
+func (ws *WalletServer) HDRestore(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		s := ws.session(res, req)
+		if s == nil {
+			return
+		}
+		decoder := json.NewDecoder(req.Body)
+		var hr common.HDRestoreRequest
+		if err := decoder.Decode(&hr); err != nil || !hr.Validate() {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing mnemonic or passphrase")
+			return
+		}
+		mnemonicPassphrase := ""
+		if hr.MnemonicPassphrase != nil {
+			mnemonicPassphrase = *hr.MnemonicPassphrase
+		}
+		kf, err := s.keystore.RestoreHD(*hr.Mnemonic, mnemonicPassphrase, *hr.Passphrase, ws.used)
+		if errors.Is(err, errGateway) {
+			log.Printf("ERROR: %v", err)
+			common.WriteError(res, http.StatusBadGateway, common.ERR_GATEWAY, err.Error())
+			return
+		}
+		if err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		ws.serve(res, s, hr.Name, kf, *hr.Passphrase, "")
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
+
+// HDAccounts lists the addresses of the wallet on GET and derives the next
+// one on POST, which needs the wallet unlocked.
+func (ws *WalletServer) HDAccounts(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		s := ws.walletSession(res, req)
+		if s == nil {
+			return
+		}
+		name, address := s.Wallet()
+		accounts, err := s.keystore.Accounts(address)
+		if err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		res.Header().Add("Content-Type", "application/json")
+		m, _ := json.Marshal(hdWallet{Name: name, Address: address, Accounts: accounts})
+		io.WriteString(res, string(m[:]))
+	case http.MethodPost:
+		s := ws.walletSession(res, req)
+		if s == nil {
+			return
+		}
+		account, err := s.keystore.NewAccount(s.Address())
+		if err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		res.Header().Add("Content-Type", "application/json")
+		res.WriteHeader(http.StatusCreated)
+		m, _ := json.Marshal(account)
+		io.WriteString(res, string(m[:]))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodGet, http.MethodPost)
+	}
+}
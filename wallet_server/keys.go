@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"goblockchain/common"
+	"goblockchain/wallet"
+	"io"
+	"log"
+	"net/http"
+)
+
+type keyInfo struct {
+	Name              string `json:"name,omitempty"`
+	Format            string `json:"format,omitempty"`
+	PrivateKey        string `json:"private_key,omitempty"`
+	Scheme            string `json:"scheme"`
+	PublicKey         string `json:"public_key"`
+	BlockchainAddress string `json:"blockchain_address"`
+}
+
+// parseKey reads the private key of kr, of the scheme it names if any.
+func (ws *WalletServer) parseKey(kr *common.KeyRequest) (*wallet.Wallet, error) {
+	var scheme common.SignatureScheme
+	if kr.Scheme != nil {
+		var err error
+		if scheme, err = common.SchemeByName(*kr.Scheme); err != nil {
+			return nil, err
+		}
+	}
+	return wallet.ParsePrivateKey(*kr.PrivateKey, scheme, ws.network)
+}
+
+// KeyAddress derives the address of a private key given as hex, WIF or
+// PEM, without storing it.
+func (ws *WalletServer) KeyAddress(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		decoder := json.NewDecoder(req.Body)
+		var kr common.KeyRequest
+		if err := decoder.Decode(&kr); err != nil || !kr.Validate() {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing private key")
+			return
+		}
+		w, err := ws.parseKey(&kr)
+		if err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		res.Header().Add("Content-Type", "application/json")
+		m, _ := json.Marshal(keyInfo{Scheme: w.Scheme().Name(), PublicKey: w.PublicKeyString(), BlockchainAddress: w.BlockchainAddress()})
+		io.WriteString(res, string(m[:]))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
+
+// KeyImport stores a private key in the user's keystore, encrypted with
+// passphrase, and adds its wallet to the user's.
+func (ws *WalletServer) KeyImport(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		s := ws.session(res, req)
+		if s == nil {
+			return
+		}
+		decoder := json.NewDecoder(req.Body)
+		var kr common.KeyRequest
+		if err := decoder.Decode(&kr); err != nil || !kr.Validate() || kr.Passphrase == nil {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing private key or passphrase")
+			return
+		}
+		w, err := ws.parseKey(&kr)
+		if err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		if err := s.keystore.Store(w, *kr.Passphrase); err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		name := ""
+		if kr.Name != nil {
+			name = *kr.Name
+		}
+		nw, err := ws.addWallet(s, name, w.BlockchainAddress(), *kr.Passphrase)
+		if err != nil {
+			ws.userError(res, err)
+			return
+		}
+		res.Header().Add("Content-Type", "application/json")
+		res.WriteHeader(http.StatusCreated)
+		m, _ := json.Marshal(keyInfo{Name: nw.Name, Scheme: w.Scheme().Name(), PublicKey: w.PublicKeyString(), BlockchainAddress: w.BlockchainAddress()})
+		io.WriteString(res, string(m[:]))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
+
+// KeyExport returns the private key of one of the selected wallet's
+// addresses, the first one by default, in the requested format. This is
+// the only way private keys leave the server, and it takes the user's
+// password and the wallet's passphrase again even while logged in and
+// unlocked.
+func (ws *WalletServer) KeyExport(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		s := ws.walletSession(res, req)
+		if s == nil {
+			return
+		}
+		decoder := json.NewDecoder(req.Body)
+		var kr common.KeyExportRequest
+		if err := decoder.Decode(&kr); err != nil || !kr.Validate() {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing password, passphrase or format")
+			return
+		}
+		if err := ws.users.Authenticate(s.user, *kr.Password); err != nil {
+			log.Printf("ERROR: key export by %q: %v", s.user, err)
+			common.WriteError(res, http.StatusForbidden, common.ERR_UNAUTHORIZED, "wrong password")
+			return
+		}
+		entry := s.Address()
+		address := entry
+		if kr.Address != nil && *kr.Address != "" {
+			a, err := ws.network.ParseAddress(*kr.Address)
+			if err != nil {
+				ws.keystoreError(res, err)
+				return
+			}
+			address = a
+		}
+		w, err := s.keystore.Decrypt(entry, address, *kr.Passphrase)
+		if err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		key, err := w.ExportPrivateKey(*kr.Format, ws.network)
+		if err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		res.Header().Add("Content-Type", "application/json")
+		res.Header().Add("Cache-Control", "no-store")
+		m, _ := json.Marshal(keyInfo{
+			Format:            *kr.Format,
+			PrivateKey:        key,
+			Scheme:            w.Scheme().Name(),
+			PublicKey:         w.PublicKeyString(),
+			BlockchainAddress: w.BlockchainAddress(),
+		})
+		io.WriteString(res, string(m[:]))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
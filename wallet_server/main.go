@@ -2,7 +2,10 @@ package main
 
 import (
 	"flag"
+	"goblockchain/common"
 	"log"
+	"os"
+	"path/filepath"
 )
 
 func init() {
@@ -10,9 +13,27 @@ func init() {
 }
 
 func main() {
-	port := flag.Uint("port", 8080, "TCP port for Wallet")
-	gateway := flag.Uint("gateway", 5000, "Gateway Port")
+	port := flag.Uint("port", 0, "TCP port for Wallet (default: network wallet port)")
+	gateway := flag.Uint("gateway", 0, "Gateway Port (default: network port)")
+	networkName := flag.String("network", common.MAINNET.Name, "Network profile: mainnet, testnet or regtest")
+	keystoreDir := flag.String("keystore", "", "Directory of the users and their encrypted key files (default: keystore/<network>)")
 	flag.Parse()
-	app := NewWalletServer(uint16(*port), uint16(*gateway))
+	network, err := common.NetworkByName(*networkName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *port == 0 {
+		*port = uint(network.WalletPort)
+	}
+	if *gateway == 0 {
+		*gateway = uint(network.DefaultPort)
+	}
+	if *keystoreDir == "" {
+		*keystoreDir = filepath.Join("keystore", network.Name)
+	}
+	if err := os.MkdirAll(*keystoreDir, 0700); err != nil {
+		log.Fatal(err)
+	}
+	app := NewWalletServer(uint16(*port), uint16(*gateway), network, *keystoreDir)
 	app.Run()
 }
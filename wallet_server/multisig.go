@@ -0,0 +1,530 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goblockchain/common"
+	"goblockchain/wallet"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoPending     = errors.New("no such pending transaction")
+	ErrAlreadySigned = errors.New("your keys have signed already")
+	ErrStalePending  = errors.New("the nonce of the pending transaction was used since, propose it again")
+	ErrNotDiscarder  = errors.New("only the proposer or a holder of a key of the policy may discard it")
+)
+
+// PendingTransaction is a transaction from a multisig address collecting
+// the signatures of its cosigners, users of the server whose wallets hold
+// keys of its policy, until it has enough to be submitted.
+type PendingTransaction struct {
+	ID          string              `json:"id"`
+	Proposer    string              `json:"proposer"`
+	CreatedAt   int64               `json:"created_at"`
+	Transaction *common.Transaction `json:"transaction"`
+}
+
+// signed counts the signatures p has collected.
+func (p *PendingTransaction) signed() int {
+	n := 0
+	for _, s := range p.Transaction.Signatures {
+		if s != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// copy is a copy of p whose signatures can be read while p collects more.
+func (p *PendingTransaction) copy() *PendingTransaction {
+	c := *p
+	t := *p.Transaction
+	t.Signatures = append([]*common.Signature{}, t.Signatures...)
+	c.Transaction = &t
+	return &c
+}
+
+// pendingStore keeps the pending transactions of every user in a single
+// file, as cosigners are several users.
+type pendingStore struct {
+	path         string
+	transactions map[string]*PendingTransaction
+	mux          sync.Mutex
+}
+
+func newPendingStore(path string) *pendingStore {
+	return &pendingStore{path: path, transactions: make(map[string]*PendingTransaction)}
+}
+
+func (ps *pendingStore) load() error {
+	data, err := os.ReadFile(ps.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var transactions []*PendingTransaction
+	if err := json.Unmarshal(data, &transactions); err != nil {
+		return fmt.Errorf("%s: %v", ps.path, err)
+	}
+
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+	for _, p := range transactions {
+		ps.transactions[p.ID] = p
+	}
+	log.Printf("action=multisig, status=loaded, count=%d", len(transactions))
+	return nil
+}
+
+// save writes the pending transactions to path. The caller must hold
+// ps.mux.
+func (ps *pendingStore) save() error {
+	transactions := make([]*PendingTransaction, 0, len(ps.transactions))
+	for _, p := range ps.transactions {
+		transactions = append(transactions, p)
+	}
+	data, _ := json.MarshalIndent(transactions, "", "  ")
+	tmp := ps.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ps.path)
+}
+
+// Add stores p, with the lowest nonce from the one of its transaction that
+// no other pending transaction of the same sender holds. Nonces freed by
+// discarded transactions are so taken again, leaving no gap the chain
+// would wait on.
+func (ps *pendingStore) Add(p *PendingTransaction) error {
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+	t := &p.Transaction.Tx
+	held := make(map[uint64]bool)
+	for _, other := range ps.transactions {
+		if other.Transaction.Tx.SenderAddress == t.SenderAddress {
+			held[other.Transaction.Tx.Nonce] = true
+		}
+	}
+	for held[t.Nonce] {
+		t.Nonce++
+	}
+	ps.transactions[p.ID] = p
+	if err := ps.save(); err != nil {
+		delete(ps.transactions, p.ID)
+		return err
+	}
+	return nil
+}
+
+// Get returns a copy of the pending transaction id.
+func (ps *pendingStore) Get(id string) (*PendingTransaction, error) {
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+	p, ok := ps.transactions[id]
+	if !ok {
+		return nil, ErrNoPending
+	}
+	return p.copy(), nil
+}
+
+// List returns copies of the pending transactions, oldest first.
+func (ps *pendingStore) List() []*PendingTransaction {
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+	transactions := make([]*PendingTransaction, 0, len(ps.transactions))
+	for _, p := range ps.transactions {
+		transactions = append(transactions, p.copy())
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].CreatedAt < transactions[j].CreatedAt
+	})
+	return transactions
+}
+
+// Update applies f to the pending transaction id and saves it, unless f
+// fails. It returns a copy of the transaction as updated.
+func (ps *pendingStore) Update(id string, f func(p *PendingTransaction) error) (*PendingTransaction, error) {
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+	p, ok := ps.transactions[id]
+	if !ok {
+		return nil, ErrNoPending
+	}
+	updated := p.copy()
+	if err := f(updated); err != nil {
+		return nil, err
+	}
+	ps.transactions[id] = updated
+	if err := ps.save(); err != nil {
+		ps.transactions[id] = p
+		return nil, err
+	}
+	return updated.copy(), nil
+}
+
+func (ps *pendingStore) Delete(id string) error {
+	ps.mux.Lock()
+	defer ps.mux.Unlock()
+	p, ok := ps.transactions[id]
+	if !ok {
+		return ErrNoPending
+	}
+	delete(ps.transactions, id)
+	if err := ps.save(); err != nil {
+		ps.transactions[id] = p
+		return err
+	}
+	return nil
+}
+
+type cosignerInfo struct {
+	Address   string `json:"address"`
+	Scheme    string `json:"scheme"`
+	PublicKey string `json:"public_key"`
+	Signed    bool   `json:"signed"`
+}
+
+type pendingInfo struct {
+	ID          string              `json:"id"`
+	Proposer    string              `json:"proposer"`
+	CreatedAt   int64               `json:"created_at"`
+	SigningHash string              `json:"signing_hash"`
+	Required    int                 `json:"required"`
+	Signed      int                 `json:"signed"`
+	Cosigners   []cosignerInfo      `json:"cosigners"`
+	Transaction *common.Transaction `json:"transaction"`
+}
+
+func (ws *WalletServer) pendingInfo(p *PendingTransaction) *pendingInfo {
+	ms := p.Transaction.Multisig
+	h := common.TransactionSigningHash(&p.Transaction.Tx)
+	info := &pendingInfo{
+		ID:          p.ID,
+		Proposer:    p.Proposer,
+		CreatedAt:   p.CreatedAt,
+		SigningHash: hex.EncodeToString(h[:]),
+		Required:    ms.M,
+		Signed:      p.signed(),
+		Cosigners:   make([]cosignerInfo, len(ms.Keys)),
+		Transaction: p.Transaction,
+	}
+	for i, k := range ms.Keys {
+		info.Cosigners[i] = cosignerInfo{
+			Address:   common.AddressFromPublicKey(k, ws.network.AddressVersion),
+			Scheme:    k.Scheme.Name(),
+			PublicKey: k.String(),
+			Signed:    p.Transaction.Signatures[i] != nil,
+		}
+	}
+	return info
+}
+
+func (ws *WalletServer) writePending(res http.ResponseWriter, p *PendingTransaction, status int) {
+	res.Header().Add("Content-Type", "application/json")
+	res.WriteHeader(status)
+	m, _ := json.Marshal(ws.pendingInfo(p))
+	io.WriteString(res, string(m[:]))
+}
+
+// userAddresses is the set of the addresses of every wallet of the user
+// of s, those of HD wallets included.
+func (ws *WalletServer) userAddresses(s *session) map[string]bool {
+	wallets, _ := ws.users.Wallets(s.user)
+	addresses := make(map[string]bool)
+	for _, w := range wallets {
+		accounts, err := s.keystore.Accounts(w.Address)
+		if err != nil {
+			continue
+		}
+		for _, a := range accounts {
+			addresses[a.Address] = true
+		}
+	}
+	return addresses
+}
+
+// participates tells whether the user of s, whose addresses are given, has
+// a say in p: as a user of its multisig wallet or a holder of one of its
+// keys.
+func (ws *WalletServer) participates(addresses map[string]bool, p *PendingTransaction) bool {
+	if addresses[p.Transaction.Tx.SenderAddress] {
+		return true
+	}
+	for _, k := range p.Transaction.Multisig.Keys {
+		if addresses[common.AddressFromPublicKey(k, ws.network.AddressVersion)] {
+			return true
+		}
+	}
+	return false
+}
+
+// mayDiscard tells whether the user of s proposed p or holds a key of its
+// policy. Anyone can watch the address of a policy, its keys being public,
+// which only gives a say in seeing its transactions.
+func (ws *WalletServer) mayDiscard(s *session, p *PendingTransaction) bool {
+	if p.Proposer == s.user {
+		return true
+	}
+	for _, k := range p.Transaction.Multisig.Keys {
+		if _, err := s.keystore.Entry(common.AddressFromPublicKey(k, ws.network.AddressVersion)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// pending finds the pending transaction id the user of s takes part in,
+// or replies 404 and returns nil.
+func (ws *WalletServer) pending(res http.ResponseWriter, s *session, id string) *PendingTransaction {
+	p, err := ws.pendingTransactions.Get(id)
+	if err == nil && !ws.participates(ws.userAddresses(s), p) {
+		err = ErrNoPending
+	}
+	if err != nil {
+		ws.userError(res, err)
+		return nil
+	}
+	return p
+}
+
+// Multisig creates a wallet of the address of an M-of-N policy. It holds
+// no key: transactions from it are proposed to its cosigners, who sign
+// them with their own wallets.
+func (ws *WalletServer) Multisig(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		s := ws.session(res, req)
+		if s == nil {
+			return
+		}
+		decoder := json.NewDecoder(req.Body)
+		var mr common.MultisigCreateRequest
+		if err := decoder.Decode(&mr); err != nil || !mr.Validate() {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing m or keys")
+			return
+		}
+		keys, err := common.MultisigKeys(mr.Keys)
+		if err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		ms, err := common.NewMultisig(*mr.M, keys)
+		if err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		kf, err := s.keystore.AddMultisig(ms)
+		if err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		name := ""
+		if mr.Name != nil {
+			name = *mr.Name
+		}
+		if _, err := ws.addWallet(s, name, kf.Address, ""); err != nil {
+			ws.userError(res, err)
+			return
+		}
+		log.Printf("action=multisig, user=%s, address=%s, policy=%d-of-%d", s.user, kf.Address, ms.M, len(ms.Keys))
+		ws.writeSession(res, s, http.StatusCreated)
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
+
+// MultisigTransactions lists the pending transactions the user takes part
+// in on GET, or the one of ?id=, proposes a transaction from the selected
+// multisig wallet on POST and discards the one of ?id= on DELETE, for its
+// proposer or a holder of a key of its policy.
+func (ws *WalletServer) MultisigTransactions(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		s := ws.session(res, req)
+		if s == nil {
+			return
+		}
+		if id := req.URL.Query().Get("id"); id != "" {
+			if p := ws.pending(res, s, id); p != nil {
+				ws.writePending(res, p, http.StatusOK)
+			}
+			return
+		}
+		addresses := ws.userAddresses(s)
+		infos := make([]*pendingInfo, 0)
+		for _, p := range ws.pendingTransactions.List() {
+			if ws.participates(addresses, p) {
+				infos = append(infos, ws.pendingInfo(p))
+			}
+		}
+		res.Header().Add("Content-Type", "application/json")
+		m, _ := json.Marshal(infos)
+		io.WriteString(res, string(m[:]))
+	case http.MethodPost:
+		s := ws.walletSession(res, req)
+		if s == nil {
+			return
+		}
+		ms, err := s.keystore.Multisig(s.Address())
+		if err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		t := ws.transactionRequest(res, req, s)
+		if t == nil {
+			return
+		}
+		p := &PendingTransaction{
+			ID:        randomHex(16),
+			Proposer:  s.user,
+			CreatedAt: time.Now().Unix(),
+			Transaction: &common.Transaction{
+				Multisig:   ms,
+				Signatures: make([]*common.Signature, len(ms.Keys)),
+				Tx:         *t,
+			},
+		}
+		if err := ws.pendingTransactions.Add(p); err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		log.Printf("action=multisig_propose, user=%s, id=%s, sender=%s", s.user, p.ID, t.SenderAddress)
+		ws.writePending(res, p, http.StatusCreated)
+	case http.MethodDelete:
+		s := ws.session(res, req)
+		if s == nil {
+			return
+		}
+		id := req.URL.Query().Get("id")
+		p := ws.pending(res, s, id)
+		if p == nil {
+			return
+		}
+		if !ws.mayDiscard(s, p) {
+			ws.userError(res, ErrNotDiscarder)
+			return
+		}
+		if err := ws.pendingTransactions.Delete(id); err != nil {
+			ws.userError(res, err)
+			return
+		}
+		log.Printf("action=multisig_discard, user=%s, id=%s", s.user, id)
+		res.Header().Add("Content-Type", "application/json")
+		io.WriteString(res, string(common.JsonStatus("discarded")))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodGet, http.MethodPost, http.MethodDelete)
+	}
+}
+
+// MultisigSign co-signs a pending transaction with every unlocked wallet of
+// the user holding a key of its policy, until it has the M signatures it
+// needs. It is then submitted to the gateway, whose reply is passed on, and
+// forgotten once accepted; if it is refused, signing it again retries. One
+// whose nonce was used since it was proposed is refused: this is checked
+// while it is signed, the gateway still rejecting it should the nonce be
+// used between then and its submission.
+func (ws *WalletServer) MultisigSign(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		s := ws.session(res, req)
+		if s == nil {
+			return
+		}
+		decoder := json.NewDecoder(req.Body)
+		var mr common.MultisigSignRequest
+		if err := decoder.Decode(&mr); err != nil || !mr.Validate() {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing id")
+			return
+		}
+		if p := ws.pending(res, s, *mr.ID); p == nil {
+			return
+		}
+		addresses := ws.userAddresses(s)
+		p, err := ws.pendingTransactions.Update(*mr.ID, func(p *PendingTransaction) error {
+			// signatures of a transaction the chain no longer accepts are wasted
+			next, err := ws.nonce(p.Transaction.Tx.SenderAddress)
+			if err != nil {
+				return err
+			}
+			if p.Transaction.Tx.Nonce < next {
+				return ErrStalePending
+			}
+			return ws.coSign(s, addresses, p)
+		})
+		if errors.Is(err, errGateway) {
+			log.Printf("ERROR: %v", err)
+			common.WriteError(res, http.StatusBadGateway, common.ERR_GATEWAY, err.Error())
+			return
+		}
+		if err != nil {
+			ws.userError(res, err)
+			return
+		}
+		log.Printf("action=multisig_sign, user=%s, id=%s, signed=%d/%d", s.user, p.ID, p.signed(), p.Transaction.Multisig.M)
+		if p.signed() < p.Transaction.Multisig.M {
+			ws.writePending(res, p, http.StatusOK)
+			return
+		}
+		if ws.submit(res, p.Transaction) == http.StatusCreated {
+			if err := ws.pendingTransactions.Delete(p.ID); err != nil {
+				log.Printf("ERROR: %v", err)
+			}
+		}
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
+
+// coSign adds to p the signatures of the unlocked wallets of the user of s
+// that are keys of its policy and have not signed yet, up to M of them. It
+// fails if the user could add none to a transaction still short of
+// signatures.
+func (ws *WalletServer) coSign(s *session, addresses map[string]bool, p *PendingTransaction) error {
+	t := p.Transaction
+	signed, locked, mine := p.signed(), false, false
+	if signed >= t.Multisig.M {
+		return nil
+	}
+	added := 0
+	for i, k := range t.Multisig.Keys {
+		address := common.AddressFromPublicKey(k, ws.network.AddressVersion)
+		if !addresses[address] {
+			continue
+		}
+		mine = true
+		if signed == t.Multisig.M || t.Signatures[i] != nil {
+			continue
+		}
+		w, err := s.keystore.Wallet(address)
+		if err != nil {
+			locked = true
+			continue
+		}
+		if err := w.CoSign(t); err != nil {
+			return err
+		}
+		signed++
+		added++
+	}
+	switch {
+	case added > 0:
+		return nil
+	case locked:
+		return fmt.Errorf("%w: unlock your wallets that are keys of the policy", wallet.ErrWalletLocked)
+	case mine:
+		return ErrAlreadySigned
+	default:
+		return wallet.ErrNotCosigner
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"goblockchain/common"
+	"goblockchain/wallet"
+	"path/filepath"
+	"testing"
+)
+
+func TestPendingStoreNonces(t *testing.T) {
+	ps := newPendingStore(filepath.Join(t.TempDir(), "multisig.json"))
+	// add proposes from sender with the nonce the gateway tells next
+	add := func(id string, sender string, next uint64) uint64 {
+		p := &PendingTransaction{ID: id, Transaction: &common.Transaction{
+			Tx: common.BlockTransaction{SenderAddress: sender, Nonce: next},
+		}}
+		if err := ps.Add(p); err != nil {
+			t.Fatal(err)
+		}
+		return p.Transaction.Tx.Nonce
+	}
+
+	for i, want := range []uint64{5, 6, 7} {
+		if got := add(string(rune('a'+i)), "A", 5); got != want {
+			t.Errorf("nonce of proposal %d = %d, want %d", i, got, want)
+		}
+	}
+	if got := add("other", "B", 5); got != 5 {
+		t.Errorf("nonce of another sender = %d, want 5", got)
+	}
+	// the nonce of a discarded proposal is the first taken again
+	if err := ps.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if got := add("d", "A", 5); got != 5 {
+		t.Errorf("nonce after a discard = %d, want 5", got)
+	}
+	if got := add("e", "A", 5); got != 8 {
+		t.Errorf("nonce after the gap is filled = %d, want 8", got)
+	}
+	// once accepted, the gateway's next is past it
+	if got := add("f", "A", 9); got != 9 {
+		t.Errorf("nonce past the pending ones = %d, want 9", got)
+	}
+}
+
+// cosigner is a session of a user holding, unlocked or not, a wallet of
+// scheme.
+func cosigner(t *testing.T, user string, scheme common.SignatureScheme, unlocked bool) (*session, *wallet.Wallet) {
+	t.Helper()
+	ks, err := wallet.NewKeystore(t.TempDir(), common.REGTEST)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := ks.Create(scheme, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unlocked {
+		if _, err := ks.Unlock(w.BlockchainAddress(), "passphrase", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &session{user: user, keystore: ks}, w
+}
+
+func TestCoSign(t *testing.T) {
+	ws := &WalletServer{network: common.REGTEST}
+	alice, aw := cosigner(t, "alice", common.SECP256K1, true)
+	bob, bw := cosigner(t, "bob", common.ED25519, false)
+	carol, cw := cosigner(t, "carol", common.P256, true)
+	mallory, _ := cosigner(t, "mallory", common.P256, true)
+	ms, err := common.NewMultisig(2, []*common.PublicKey{aw.PublicKey(), bw.PublicKey(), cw.PublicKey()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &PendingTransaction{ID: "id", Proposer: "dave", Transaction: &common.Transaction{
+		Multisig:   ms,
+		Signatures: make([]*common.Signature, len(ms.Keys)),
+		Tx:         common.BlockTransaction{SenderAddress: ms.Address(common.REGTEST), RecipientAddress: aw.BlockchainAddress(), Value: 1},
+	}}
+	addresses := func(w *wallet.Wallet) map[string]bool {
+		return map[string]bool{w.BlockchainAddress(): true}
+	}
+
+	steps := []struct {
+		name      string
+		s         *session
+		addresses map[string]bool
+		err       error
+		signed    int
+	}{
+		{"not a cosigner", mallory, map[string]bool{}, wallet.ErrNotCosigner, 0},
+		{"locked", bob, addresses(bw), wallet.ErrWalletLocked, 0},
+		{"first", alice, addresses(aw), nil, 1},
+		{"again", alice, addresses(aw), ErrAlreadySigned, 1},
+		{"second", carol, addresses(cw), nil, 2},
+		{"enough", bob, addresses(bw), nil, 2},
+	}
+	for _, step := range steps {
+		if err := ws.coSign(step.s, step.addresses, p); !errors.Is(err, step.err) {
+			t.Errorf("%s: coSign() = %v, want %v", step.name, err, step.err)
+		}
+		if got := p.signed(); got != step.signed {
+			t.Errorf("%s: %d signatures, want %d", step.name, got, step.signed)
+		}
+	}
+
+	for _, tt := range []struct {
+		s    *session
+		want bool
+	}{{alice, true}, {bob, true}, {mallory, false}, {&session{user: "dave", keystore: mallory.keystore}, true}} {
+		if got := ws.mayDiscard(tt.s, p); got != tt.want {
+			t.Errorf("mayDiscard(%s) = %t, want %t", tt.s.user, got, tt.want)
+		}
+	}
+	// watching the address of a key gives no say
+	if _, err := mallory.keystore.Watch(aw.BlockchainAddress()); err != nil {
+		t.Fatal(err)
+	}
+	if ws.mayDiscard(mallory, p) {
+		t.Error("mayDiscard() of a user watching a key of the policy")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"goblockchain/blockchain"
+	"goblockchain/common"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type unsignedTransaction struct {
+	Transaction *common.UnsignedTransaction `json:"transaction"`
+	Encoded     string                      `json:"encoded"`
+}
+
+// context fills in the sender's confirmed balance and the chain tip the
+// gateway sees, for the signer to review.
+func (ws *WalletServer) context(u *common.UnsignedTransaction) error {
+	response, err := http.Get(ws.Gateway() + common.API_PREFIX + "/amounts?address=" + u.SenderAddress)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errGateway, err)
+	}
+	amount, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: status %d", errGateway, response.StatusCode)
+	}
+	if u.Balance, err = strconv.ParseFloat(string(amount), 64); err != nil {
+		return fmt.Errorf("%w: invalid amount", errGateway)
+	}
+
+	response, err = http.Get(ws.Gateway() + common.API_PREFIX + "/tip")
+	if err != nil {
+		return fmt.Errorf("%w: %v", errGateway, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: status %d", errGateway, response.StatusCode)
+	}
+	var tip struct {
+		Height int    `json:"height"`
+		Hash   string `json:"hash"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&tip); err != nil {
+		return fmt.Errorf("%w: %v", errGateway, err)
+	}
+	u.Height, u.TipHash = tip.Height, tip.Hash
+	return nil
+}
+
+// WalletWatch adds a watch-only wallet of an address whose key is kept by
+// an offline signer.
+func (ws *WalletServer) WalletWatch(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		s := ws.session(res, req)
+		if s == nil {
+			return
+		}
+		decoder := json.NewDecoder(req.Body)
+		var wr common.WalletWatchRequest
+		if err := decoder.Decode(&wr); err != nil || !wr.Validate() {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing address")
+			return
+		}
+		name := ""
+		if wr.Name != nil {
+			name = *wr.Name
+		}
+		kf, err := s.keystore.Watch(*wr.Address)
+		if err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		if _, err := ws.addWallet(s, name, kf.Address, ""); err != nil {
+			ws.userError(res, err)
+			return
+		}
+		ws.writeSession(res, s, http.StatusCreated)
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
+
+// Unsigned builds the transaction a TransactionRequest asks for without
+// signing it, for any wallet, locked or watch-only. It is returned as JSON
+// and as a string, either of which an offline signer accepts.
+func (ws *WalletServer) Unsigned(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		s := ws.walletSession(res, req)
+		if s == nil {
+			return
+		}
+		t := ws.transactionRequest(res, req, s)
+		if t == nil {
+			return
+		}
+		if s.keystore.IsMultisig(s.Address()) {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "multisig wallet, propose the transaction to its cosigners")
+			return
+		}
+		u := common.NewUnsignedTransaction(ws.network, t)
+		u.CreatedAt = time.Now().Unix()
+		if err := ws.context(u); err != nil {
+			log.Printf("ERROR: %v", err)
+			common.WriteError(res, http.StatusBadGateway, common.ERR_GATEWAY, err.Error())
+			return
+		}
+		log.Printf("action=unsigned, user=%s, sender=%s, hash=%s", s.user, u.SenderAddress, u.SigningHash)
+		res.Header().Add("Content-Type", "application/json")
+		m, _ := json.Marshal(unsignedTransaction{Transaction: u, Encoded: u.Encode()})
+		io.WriteString(res, string(m[:]))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
+
+// Broadcast checks a transaction signed offline, signature and nonce, and
+// submits it to the gateway.
+func (ws *WalletServer) Broadcast(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		if s := ws.session(res, req); s == nil {
+			return
+		}
+		decoder := json.NewDecoder(req.Body)
+		var br common.BroadcastRequest
+		if err := decoder.Decode(&br); err != nil || !br.Validate() {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing transaction")
+			return
+		}
+		t, err := common.DecodeSignedTransaction([]byte(*br.Transaction))
+		if err != nil {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, err.Error())
+			return
+		}
+		for _, address := range []string{t.Tx.SenderAddress, t.Tx.RecipientAddress} {
+			if err := ws.network.ValidateAddress(address); err != nil {
+				common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, err.Error())
+				return
+			}
+		}
+		if err := blockchain.CheckTransactionSignature(t); err != nil {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, err.Error())
+			return
+		}
+		// the gateway would reject it too, told here with what it expects
+		next, err := ws.nonce(t.Tx.SenderAddress)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			common.WriteError(res, http.StatusBadGateway, common.ERR_GATEWAY, err.Error())
+			return
+		}
+		if t.Tx.Nonce != next {
+			common.WriteError(res, http.StatusConflict, common.ERR_CONFLICT,
+				fmt.Sprintf("nonce %d, the sender's next is %d: build the transaction again", t.Tx.Nonce, next))
+			return
+		}
+		ws.submit(res, t)
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"bytes"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"goblockchain/common"
 	jsonUtils "goblockchain/common"
@@ -13,29 +15,43 @@ import (
 	"log"
 	"net/http"
 	"path"
+	"path/filepath"
 	"strconv"
+	"sync"
+	"time"
 )
 
 const tempDir = "wallet_server/templates"
 
+//go:embed openapi.yaml
+var openapiSpec []byte
+
 type WalletServer struct {
-	port    uint16
-	gateway uint16
-	wallet  wallet.Wallet
+	port                uint16
+	gateway             uint16
+	network             *common.Network
+	dir                 string
+	users               *userStore
+	pendingTransactions *pendingStore
+	sessions            map[string]*session
+	keystores           map[string]*wallet.Keystore
+	mux                 sync.Mutex
 }
 
-func NewWalletServer(port uint16, gateway uint16) *WalletServer {
-	wallet := wallet.NewWallet()
-	ws := &WalletServer{port, gateway, *wallet}
-
-	//give them some money...
-	t := wallet.CreateTransaction(wallet.BlockchainAddress(), 100)
-	t.Tx.SenderAddress = "THE BLOCKCHAIN"
-	m, _ := json.Marshal(t)
-	buf := bytes.NewBuffer(m)
-	http.Post(ws.Gateway()+"/transactions", "application/json", buf)
-
-	return ws
+// NewWalletServer serves the users registered in dir, each with a
+// keystore of encrypted wallets in a subdirectory, and the multisig
+// transactions they are co-signing.
+func NewWalletServer(port uint16, gateway uint16, network *common.Network, dir string) *WalletServer {
+	return &WalletServer{
+		port:                port,
+		gateway:             gateway,
+		network:             network,
+		dir:                 dir,
+		users:               newUserStore(filepath.Join(dir, "users.json")),
+		pendingTransactions: newPendingStore(filepath.Join(dir, "multisig.json")),
+		sessions:            make(map[string]*session),
+		keystores:           make(map[string]*wallet.Keystore),
+	}
 }
 
 func (ws *WalletServer) Port() uint16 {
@@ -50,105 +66,467 @@ func (ws *WalletServer) Gateway() string {
 	return fmt.Sprintf("http://localhost:%d", ws.gateway)
 }
 
+// RequestFaucet asks the gateway's faucet to fund address.
+func (ws *WalletServer) RequestFaucet(address string) error {
+	m, _ := json.Marshal(common.FaucetRequest{Address: &address})
+	buf := bytes.NewBuffer(m)
+	response, err := http.Post(ws.Gateway()+common.API_PREFIX+"/faucet", "application/json", buf)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusCreated {
+		return fmt.Errorf("faucet refused with status %d", response.StatusCode)
+	}
+	return nil
+}
+
+// fund asks the faucet, if the network has one, for some money for a new
+// wallet.
+func (ws *WalletServer) fund(address string) {
+	if !ws.network.Faucet {
+		return
+	}
+	go func() {
+		if err := ws.RequestFaucet(address); err != nil {
+			log.Printf("ERROR: %v", err)
+		}
+	}()
+}
+
+// GatewayWS is the WebSocket endpoint of the gateway, which the UI listens
+// to instead of polling for its balance.
+func (ws *WalletServer) GatewayWS() string {
+	return fmt.Sprintf("ws://localhost:%d%s/ws", ws.gateway, common.API_PREFIX)
+}
+
 func (ws *WalletServer) Index(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		common.NotFound(res, req)
+		return
+	}
 	switch req.Method {
 	case http.MethodGet:
 		t, err := template.ParseFiles(path.Join(tempDir, "index.html"))
 		if err != nil {
 			panic(err)
 		} else {
-			t.Execute(res, "")
+			t.Execute(res, struct {
+				GatewayWS string
+			}{
+				GatewayWS: ws.GatewayWS(),
+			})
 		}
 	default:
-		log.Println("ERROR: Invalid HTTP Method")
+		common.MethodNotAllowed(res, req, http.MethodGet)
 	}
 }
 
+// Wallet serves the selected wallet's name, address and public key. Its
+// private key is only returned by KeyExport.
 func (ws *WalletServer) Wallet(res http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodPost:
+		s := ws.walletSession(res, req)
+		if s == nil {
+			return
+		}
+		name, address := s.Wallet()
+		kf, err := s.keystore.Load(address)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			common.WriteError(res, http.StatusInternalServerError, common.ERR_INTERNAL, "cannot read keystore")
+			return
+		}
+		bech32Address, _ := ws.network.Bech32Address(kf.Address)
+		scheme := kf.Scheme
+		if scheme == "" && !s.keystore.Keyless(kf.Address) {
+			scheme = common.SCHEME_P256
+		}
+		_, err = s.keystore.Wallet(kf.Address)
+		info := struct {
+			Name              string           `json:"name"`
+			Scheme            string           `json:"scheme,omitempty"`
+			PublicKey         string           `json:"public_key"`
+			BlockchainAddress string           `json:"blockchain_address"`
+			Bech32Address     string           `json:"bech32_address"`
+			Locked            bool             `json:"locked"`
+			WatchOnly         bool             `json:"watch_only"`
+			Multisig          *common.Multisig `json:"multisig,omitempty"`
+		}{
+			Name:              name,
+			Scheme:            scheme,
+			PublicKey:         kf.PublicKey,
+			BlockchainAddress: kf.Address,
+			Bech32Address:     bech32Address,
+			Locked:            err != nil,
+			WatchOnly:         kf.Kind == wallet.KEY_KIND_WATCH,
+			Multisig:          kf.Multisig,
+		}
 		res.Header().Add("Content-Type", "application/json")
-		m, _ := ws.wallet.MarshalJSON()
+		m, _ := json.Marshal(info)
 		io.WriteString(res, string(m[:]))
 	default:
-		res.WriteHeader(http.StatusBadRequest)
-		log.Println("ERROR: Invalid HTTP Method")
+		common.MethodNotAllowed(res, req, http.MethodPost)
 	}
 }
 
+// Transaction builds a transaction to the requested recipient, signs it
+// with the unlocked wallet and submits it to the gateway.
 func (ws *WalletServer) Transaction(res http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodPost:
-		decoder := json.NewDecoder(req.Body)
-		var t common.TransactionRequest
-		err := decoder.Decode(&t)
+		s := ws.walletSession(res, req)
+		if s == nil {
+			return
+		}
+		t := ws.transactionRequest(res, req, s)
+		if t == nil {
+			return
+		}
+		if s.keystore.WatchOnly(s.Address()) {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "watch-only wallet, build an unsigned transaction to sign offline")
+			return
+		}
+		if s.keystore.IsMultisig(s.Address()) {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "multisig wallet, propose the transaction to its cosigners")
+			return
+		}
+		w, err := s.keystore.Wallet(t.SenderAddress)
 		if err != nil {
+			common.WriteError(res, http.StatusLocked, common.ERR_WALLET_LOCKED, "unlock the wallet to sign transactions")
+			return
+		}
+		transaction := w.CreateTransaction(t.RecipientAddress, t.Value, t.Fee, t.Nonce)
+		if err := w.SignTransaction(transaction); err != nil {
 			log.Printf("ERROR: %v", err)
-			io.WriteString(res, string(jsonUtils.JsonStatus("fail")))
+			common.WriteError(res, http.StatusInternalServerError, common.ERR_INTERNAL, err.Error())
 			return
 		}
-		if !t.Validate() {
-			log.Println("ERROR: missing field(s)")
-			io.WriteString(res, string(jsonUtils.JsonStatus("fail")))
+		ws.submit(res, transaction)
+
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
+
+// transactionRequest reads the TransactionRequest of req into the
+// transaction it asks for, from an address of the wallet of s and with the
+// nonce the gateway expects next from it, or replies 400 or 502 and
+// returns nil. Requests still carrying key fields are refused, so
+// no client keeps sending keys that would be ignored.
+func (ws *WalletServer) transactionRequest(res http.ResponseWriter, req *http.Request, s *session) *common.BlockTransaction {
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+	var t common.TransactionRequest
+	err := decoder.Decode(&t)
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+		common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, err.Error())
+		return nil
+	}
+	if err := t.Validate(ws.network); err != nil {
+		log.Printf("ERROR: %v", err)
+		common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, err.Error())
+		return nil
+	}
+	value, err := strconv.ParseFloat(*t.Value, 32)
+	if err != nil || value <= 0 {
+		common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "value must be a positive number")
+		return nil
+	}
+	var fee float64 = 0
+	if t.Fee != nil && *t.Fee != "" {
+		if fee, err = strconv.ParseFloat(*t.Fee, 32); err != nil || fee < 0 {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "fee must be a non-negative number")
+			return nil
+		}
+	}
+	// an HD wallet sends from whichever of its addresses is asked for
+	sender := s.Address()
+	accounts, err := s.keystore.Accounts(sender)
+	if err != nil {
+		ws.keystoreError(res, err)
+		return nil
+	}
+	if t.SenderBlockchainAddress != nil && *t.SenderBlockchainAddress != "" {
+		sender = ""
+		for _, a := range accounts {
+			if a.Address == *t.SenderBlockchainAddress {
+				sender = a.Address
+			}
+		}
+		if sender == "" {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "sender is not an address of this wallet")
+			return nil
+		}
+	}
+	nonce, err := ws.nonce(sender)
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+		common.WriteError(res, http.StatusBadGateway, common.ERR_GATEWAY, err.Error())
+		return nil
+	}
+	return &common.BlockTransaction{
+		SenderAddress:    sender,
+		RecipientAddress: *t.RecipientBlockchainAddress,
+		Value:            float32(value),
+		Fee:              float32(fee),
+		Nonce:            nonce,
+	}
+}
+
+// nonce asks the gateway for the nonce of the next transaction of address.
+func (ws *WalletServer) nonce(address string) (uint64, error) {
+	response, err := http.Get(ws.Gateway() + common.API_PREFIX + "/nonces?address=" + address)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errGateway, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%w: status %d", errGateway, response.StatusCode)
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	nonce, err := strconv.ParseUint(string(body), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid nonce", errGateway)
+	}
+	return nonce, nil
+}
+
+// submit sends a signed transaction to the gateway and passes its status
+// and body, error or transaction id, on as they are. It returns the
+// status replied.
+func (ws *WalletServer) submit(res http.ResponseWriter, transaction *common.Transaction) int {
+	m, _ := json.Marshal(transaction)
+	buf := bytes.NewBuffer(m)
+
+	response, err := http.Post(ws.Gateway()+common.API_PREFIX+"/transactions", "application/json", buf)
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+		common.WriteError(res, http.StatusBadGateway, common.ERR_GATEWAY, "gateway unreachable")
+		return http.StatusBadGateway
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	res.Header().Add("Content-Type", "application/json")
+	res.WriteHeader(response.StatusCode)
+	res.Write(body)
+	return response.StatusCode
+}
+
+func (ws *WalletServer) Faucet(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		res.Header().Add("Content-Type", "application/json")
+		if !ws.network.Faucet {
+			common.WriteError(res, http.StatusNotFound, common.ERR_NOT_FOUND, "no faucet on "+ws.network.Name)
 			return
 		}
+		s := ws.walletSession(res, req)
+		if s == nil {
+			return
+		}
+		if err := ws.RequestFaucet(s.Address()); err != nil {
+			log.Printf("ERROR: %v", err)
+			common.WriteError(res, http.StatusBadGateway, common.ERR_GATEWAY, err.Error())
+			return
+		}
+		res.WriteHeader(http.StatusCreated)
+		io.WriteString(res, string(jsonUtils.JsonStatus("success")))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
 
+// Unlock decrypts the wallet for signing, for duration seconds if given.
+func (ws *WalletServer) Unlock(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		s := ws.walletSession(res, req)
+		if s == nil {
+			return
+		}
+		decoder := json.NewDecoder(req.Body)
+		var ur common.UnlockRequest
+		if err := decoder.Decode(&ur); err != nil || !ur.Validate() {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing passphrase")
+			return
+		}
+		var duration time.Duration
+		if ur.Duration != nil {
+			duration = time.Second * time.Duration(*ur.Duration)
+		}
+		if _, err := s.keystore.Unlock(s.Address(), *ur.Passphrase, duration); err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
 		res.Header().Add("Content-Type", "application/json")
-		value, _ := strconv.ParseFloat(*t.Value, 32)
-		transaction := ws.wallet.CreateTransaction(*t.RecipientBlockchainAddress, float32(value))
-		ws.wallet.SignTransaction(transaction)
+		io.WriteString(res, string(common.JsonStatus("unlocked")))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
 
-		m, _ := json.Marshal(transaction)
-		buf := bytes.NewBuffer(m)
+func (ws *WalletServer) Lock(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		s := ws.walletSession(res, req)
+		if s == nil {
+			return
+		}
+		s.keystore.Lock(s.Address())
+		res.Header().Add("Content-Type", "application/json")
+		io.WriteString(res, string(common.JsonStatus("locked")))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
 
-		response, err := http.Post(ws.Gateway()+"/transactions", "application/json", buf)
-		if err != nil {
-			log.Printf("ERROR: %v", err)
-			res.WriteHeader(http.StatusInternalServerError)
-			io.WriteString(res, string(jsonUtils.JsonStatus("fail")))
+// Passphrase re-encrypts the wallet's key file under a new passphrase.
+func (ws *WalletServer) Passphrase(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		s := ws.walletSession(res, req)
+		if s == nil {
 			return
 		}
-		if response.StatusCode >= 200 && response.StatusCode < 300 {
-			res.WriteHeader(response.StatusCode)
-			io.WriteString(res, string(jsonUtils.JsonStatus("success")))
+		decoder := json.NewDecoder(req.Body)
+		var pr common.PassphraseRequest
+		if err := decoder.Decode(&pr); err != nil || !pr.Validate() {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing or empty passphrase")
 			return
 		}
-		res.WriteHeader(response.StatusCode)
-		io.WriteString(res, string(jsonUtils.JsonStatus("fail")))
+		if err := s.keystore.ChangePassphrase(s.Address(), *pr.OldPassphrase, *pr.NewPassphrase); err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		res.Header().Add("Content-Type", "application/json")
+		io.WriteString(res, string(common.JsonStatus("success")))
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
 
+func (ws *WalletServer) keystoreError(res http.ResponseWriter, err error) {
+	log.Printf("ERROR: %v", err)
+	switch {
+	case errors.Is(err, wallet.ErrWrongPassphrase):
+		common.WriteError(res, http.StatusForbidden, common.ERR_WRONG_PASSPHRASE, err.Error())
+	case errors.Is(err, wallet.ErrWalletLocked):
+		common.WriteError(res, http.StatusLocked, common.ERR_WALLET_LOCKED, err.Error())
+	case errors.Is(err, wallet.ErrInvalidMnemonic), errors.Is(err, wallet.ErrNotHD),
+		errors.Is(err, wallet.ErrInvalidKey), errors.Is(err, wallet.ErrKeyFormat),
+		errors.Is(err, wallet.ErrUnsupportedCurve), errors.Is(err, wallet.ErrKeystoreNetwork),
+		errors.Is(err, wallet.ErrEmptyPassphrase), errors.Is(err, wallet.ErrWatchOnly),
+		errors.Is(err, common.ErrAddressEncoding), errors.Is(err, common.ErrAddressLength),
+		errors.Is(err, common.ErrAddressChecksum), errors.Is(err, common.ErrAddressVersion),
+		errors.Is(err, common.ErrAddressBech32), errors.Is(err, common.ErrUnknownScheme),
+		errors.Is(err, wallet.ErrSchemeMismatch), errors.Is(err, wallet.ErrMultisigWallet),
+		errors.Is(err, wallet.ErrNotMultisig), errors.Is(err, wallet.ErrNotCosigner),
+		errors.Is(err, common.ErrMultisig), errors.Is(err, common.ErrPublicKey):
+		common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, err.Error())
+	case errors.Is(err, wallet.ErrNoKey):
+		common.WriteError(res, http.StatusNotFound, common.ERR_NOT_FOUND, err.Error())
 	default:
-		res.WriteHeader(http.StatusBadRequest)
-		log.Println("ERROR: Invalid HTTP Method")
+		common.WriteError(res, http.StatusInternalServerError, common.ERR_INTERNAL, err.Error())
 	}
 }
 
+// Amount is the confirmed balance of all the addresses of the wallet.
 func (ws *WalletServer) Amount(res http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodGet:
-		response, err := http.Get(ws.Gateway() + "/amounts?address=" + ws.wallet.BlockchainAddress())
-		amount, err := ioutil.ReadAll(response.Body)
+		s := ws.walletSession(res, req)
+		if s == nil {
+			return
+		}
+		accounts, err := s.keystore.Accounts(s.Address())
 		if err != nil {
-			log.Println("ERROR: No Response from Gateway")
-			res.Header().Add("Content-Type", "application/json")
-			res.WriteHeader(http.StatusInternalServerError)
-			io.WriteString(res, string(jsonUtils.JsonStatus("fail")))
+			ws.keystoreError(res, err)
 			return
 		}
-		res.WriteHeader(response.StatusCode)
-		io.WriteString(res, string(amount))
+		var total float64
+		for _, a := range accounts {
+			response, err := http.Get(ws.Gateway() + common.API_PREFIX + "/amounts?address=" + a.Address)
+			if err != nil {
+				log.Println("ERROR: No Response from Gateway")
+				common.WriteError(res, http.StatusBadGateway, common.ERR_GATEWAY, "gateway unreachable")
+				return
+			}
+			amount, _ := ioutil.ReadAll(response.Body)
+			response.Body.Close()
+			if response.StatusCode != http.StatusOK {
+				res.Header().Add("Content-Type", "application/json")
+				res.WriteHeader(response.StatusCode)
+				res.Write(amount)
+				return
+			}
+			value, err := strconv.ParseFloat(string(amount), 64)
+			if err != nil {
+				common.WriteError(res, http.StatusBadGateway, common.ERR_GATEWAY, "invalid amount from gateway")
+				return
+			}
+			total += value
+		}
+		res.Header().Add("Content-Type", "application/json")
+		io.WriteString(res, fmt.Sprintf("%f", total))
 
 	default:
-		res.WriteHeader(http.StatusBadRequest)
-		log.Println("ERROR: Invalid HTTP Method")
+		common.MethodNotAllowed(res, req, http.MethodGet)
 	}
 }
 
+// Handler serves the UI at / and the API under /v1, checked against
+// openapi.yaml, as well as unversioned.
+func (ws *WalletServer) Handler() http.Handler {
+	spec, err := common.NewAPISpec(openapiSpec)
+	if err != nil {
+		log.Fatalf("openapi.yaml: %v", err)
+	}
+
+	api := http.NewServeMux()
+	api.HandleFunc("/", ws.Index)                                     // GET
+	api.HandleFunc("/openapi.json", spec.ServeSpec)                   // GET
+	api.HandleFunc("/users", ws.Users)                                // POST
+	api.HandleFunc("/session", ws.Session)                            // GET, POST, DELETE
+	api.HandleFunc("/wallets", ws.Wallets)                            // GET, POST
+	api.HandleFunc("/wallets/select", ws.WalletSelect)                // POST
+	api.HandleFunc("/wallets/watch", ws.WalletWatch)                  // POST
+	api.HandleFunc("/wallet", ws.Wallet)                              // POST
+	api.HandleFunc("/wallet/unlock", ws.Unlock)                       // POST
+	api.HandleFunc("/wallet/lock", ws.Lock)                           // POST
+	api.HandleFunc("/wallet/passphrase", ws.Passphrase)               // POST
+	api.HandleFunc("/transaction", ws.Transaction)                    // POST
+	api.HandleFunc("/transaction/unsigned", ws.Unsigned)              // POST
+	api.HandleFunc("/broadcast", ws.Broadcast)                        // POST
+	api.HandleFunc("/amount", ws.Amount)                              // GET
+	api.HandleFunc("/faucet", ws.Faucet)                              // POST
+	api.HandleFunc("/hd/create", ws.HDCreate)                         // POST
+	api.HandleFunc("/hd/restore", ws.HDRestore)                       // POST
+	api.HandleFunc("/hd/accounts", ws.HDAccounts)                     // GET, POST
+	api.HandleFunc("/keys/address", ws.KeyAddress)                    // POST
+	api.HandleFunc("/keys/import", ws.KeyImport)                      // POST
+	api.HandleFunc("/keys/export", ws.KeyExport)                      // POST
+	api.HandleFunc("/multisig", ws.Multisig)                          // POST
+	api.HandleFunc("/multisig/transactions", ws.MultisigTransactions) // GET, POST, DELETE
+	api.HandleFunc("/multisig/sign", ws.MultisigSign)                 // POST
+
+	mux := http.NewServeMux()
+	mux.Handle(common.API_PREFIX+"/", spec.Handler(api))
+	mux.Handle("/", api)
+	return mux
+}
+
 func (ws *WalletServer) Run() {
-	http.HandleFunc("/", ws.Index)                  // GET
-	http.HandleFunc("/wallet", ws.Wallet)           // POST
-	http.HandleFunc("/transaction", ws.Transaction) // POST
-	http.HandleFunc("/amount", ws.Amount)           // GET
+	if err := ws.users.load(); err != nil {
+		log.Fatal(err)
+	}
+	if err := ws.pendingTransactions.load(); err != nil {
+		log.Fatal(err)
+	}
+	handler := ws.Handler()
 
-	log.Println("WalletServer listening on localhost:" + ws.PortStr())
-	log.Fatal(http.ListenAndServe("localhost:"+ws.PortStr(), nil))
+	log.Printf("WalletServer (%s) listening on localhost:%s", ws.network.Name, ws.PortStr())
+	log.Fatal(http.ListenAndServe("localhost:"+ws.PortStr(), handler))
 }
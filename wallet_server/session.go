@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"goblockchain/common"
+	"goblockchain/wallet"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	SESSION_COOKIE  = "wallet_session"
+	SESSION_TTL_MIN = 12 * 60 // idle minutes before a session expires
+)
+
+// session is a logged in browser. Each one works with a wallet of its
+// user of its own, while the user's wallets are unlocked for all of them.
+type session struct {
+	token    string
+	user     string
+	keystore *wallet.Keystore
+	wallet   string
+	address  string
+	expires  time.Time
+	mux      sync.Mutex
+}
+
+// Wallet is the name and key file address of the selected wallet, empty
+// if the user has none yet.
+func (s *session) Wallet() (string, string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.wallet, s.address
+}
+
+// Address is the address of the selected wallet's key file.
+func (s *session) Address() string {
+	_, address := s.Wallet()
+	return address
+}
+
+func (s *session) selectWallet(w *NamedWallet) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.wallet, s.address = w.Name, w.Address
+}
+
+type walletInfo struct {
+	Name      string `json:"name"`
+	Address   string `json:"address"`
+	Locked    bool   `json:"locked"`
+	WatchOnly bool   `json:"watch_only"`
+	Multisig  bool   `json:"multisig"`
+}
+
+type sessionInfo struct {
+	User    string       `json:"user"`
+	Wallet  string       `json:"wallet,omitempty"`
+	Wallets []walletInfo `json:"wallets"`
+}
+
+// keystoreOf opens the keystore of the user name, kept in a directory of
+// its own.
+func (ws *WalletServer) keystoreOf(name string) (*wallet.Keystore, error) {
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+	if ks, ok := ws.keystores[name]; ok {
+		return ks, nil
+	}
+	ks, err := wallet.NewKeystore(filepath.Join(ws.dir, name), ws.network)
+	if err != nil {
+		return nil, err
+	}
+	ws.keystores[name] = ks
+	return ks, nil
+}
+
+// login starts a session of the user name and sets its cookie, ending the
+// session the browser had before, if any, and the expired ones.
+func (ws *WalletServer) login(res http.ResponseWriter, req *http.Request, name string) (*session, error) {
+	ks, err := ws.keystoreOf(name)
+	if err != nil {
+		return nil, err
+	}
+	s := &session{
+		token:    randomHex(32),
+		user:     name,
+		keystore: ks,
+		expires:  time.Now().Add(time.Minute * SESSION_TTL_MIN),
+	}
+	if wallets, selected := ws.users.Wallets(name); len(wallets) > 0 {
+		w := wallets[0]
+		for _, nw := range wallets {
+			if nw.Name == selected {
+				w = nw
+			}
+		}
+		s.selectWallet(&w)
+	}
+
+	ws.mux.Lock()
+	if cookie, err := req.Cookie(SESSION_COOKIE); err == nil {
+		if old, ok := ws.sessions[cookie.Value]; ok {
+			ws.end(old)
+		}
+	}
+	for _, other := range ws.sessions {
+		if time.Now().After(other.expires) {
+			ws.end(other)
+		}
+	}
+	ws.sessions[s.token] = s
+	ws.mux.Unlock()
+	http.SetCookie(res, &http.Cookie{
+		Name:     SESSION_COOKIE,
+		Value:    s.token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	log.Printf("action=login, user=%s", name)
+	return s, nil
+}
+
+// end forgets s and locks the wallets of its user if it was their last
+// session. The caller must hold ws.mux.
+func (ws *WalletServer) end(s *session) {
+	delete(ws.sessions, s.token)
+	for _, other := range ws.sessions {
+		if other.user == s.user {
+			return
+		}
+	}
+	s.keystore.LockAll()
+	log.Printf("action=logout, user=%s", s.user)
+}
+
+func (ws *WalletServer) logout(res http.ResponseWriter, s *session) {
+	ws.mux.Lock()
+	ws.end(s)
+	ws.mux.Unlock()
+	http.SetCookie(res, &http.Cookie{Name: SESSION_COOKIE, Value: "", Path: "/", MaxAge: -1})
+}
+
+// session returns the session of req, or replies 401 and returns nil.
+func (ws *WalletServer) session(res http.ResponseWriter, req *http.Request) *session {
+	cookie, err := req.Cookie(SESSION_COOKIE)
+	if err != nil {
+		common.WriteError(res, http.StatusUnauthorized, common.ERR_UNAUTHORIZED, "log in first")
+		return nil
+	}
+	ws.mux.Lock()
+	defer ws.mux.Unlock()
+	s, ok := ws.sessions[cookie.Value]
+	if ok && time.Now().After(s.expires) {
+		ws.end(s)
+		ok = false
+	}
+	if !ok {
+		common.WriteError(res, http.StatusUnauthorized, common.ERR_UNAUTHORIZED, "session expired, log in again")
+		return nil
+	}
+	s.expires = time.Now().Add(time.Minute * SESSION_TTL_MIN)
+	return s
+}
+
+// walletSession is session for requests about the selected wallet, which
+// reply 404 when the user has none.
+func (ws *WalletServer) walletSession(res http.ResponseWriter, req *http.Request) *session {
+	s := ws.session(res, req)
+	if s == nil {
+		return nil
+	}
+	if s.Address() == "" {
+		common.WriteError(res, http.StatusNotFound, common.ERR_NOT_FOUND, "no wallet, create one first")
+		return nil
+	}
+	return s
+}
+
+// addWallet records the key file of address, unlocked with passphrase
+// unless it holds no key, as a wallet of the user of s and selects it.
+func (ws *WalletServer) addWallet(s *session, name string, address string, passphrase string) (*NamedWallet, error) {
+	w, err := ws.users.AddWallet(s.user, name, address)
+	if err != nil {
+		return nil, err
+	}
+	s.selectWallet(w)
+	if s.keystore.Keyless(address) {
+		return w, nil
+	}
+	if _, err := s.keystore.Unlock(address, passphrase, 0); err != nil {
+		return nil, err
+	}
+	log.Printf("action=wallet, user=%s, wallet=%q, address=%s", s.user, w.Name, address)
+	return w, nil
+}
+
+func (ws *WalletServer) writeSession(res http.ResponseWriter, s *session, status int) {
+	wallets, _ := ws.users.Wallets(s.user)
+	info := sessionInfo{User: s.user, Wallets: make([]walletInfo, 0, len(wallets))}
+	info.Wallet, _ = s.Wallet()
+	for _, w := range wallets {
+		_, err := s.keystore.Wallet(w.Address)
+		info.Wallets = append(info.Wallets, walletInfo{
+			Name:      w.Name,
+			Address:   w.Address,
+			Locked:    err != nil,
+			WatchOnly: s.keystore.WatchOnly(w.Address),
+			Multisig:  s.keystore.IsMultisig(w.Address),
+		})
+	}
+	res.Header().Add("Content-Type", "application/json")
+	res.WriteHeader(status)
+	m, _ := json.Marshal(info)
+	io.WriteString(res, string(m[:]))
+}
+
+func (ws *WalletServer) userError(res http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrBadCredentials):
+		common.WriteError(res, http.StatusUnauthorized, common.ERR_UNAUTHORIZED, err.Error())
+	case errors.Is(err, ErrUserExists), errors.Is(err, ErrWalletExists), errors.Is(err, ErrAlreadySigned),
+		errors.Is(err, ErrStalePending):
+		common.WriteError(res, http.StatusConflict, common.ERR_CONFLICT, err.Error())
+	case errors.Is(err, ErrNotDiscarder):
+		common.WriteError(res, http.StatusForbidden, common.ERR_FORBIDDEN, err.Error())
+	case errors.Is(err, ErrNoWallet), errors.Is(err, ErrNoPending):
+		common.WriteError(res, http.StatusNotFound, common.ERR_NOT_FOUND, err.Error())
+	default:
+		ws.keystoreError(res, err)
+	}
+}
+
+// Users registers a user, who is logged in at once.
+func (ws *WalletServer) Users(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		decoder := json.NewDecoder(req.Body)
+		var lr common.LoginRequest
+		if err := decoder.Decode(&lr); err != nil || !lr.Validate() {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing name or password")
+			return
+		}
+		if !ValidUserName(*lr.Name) || len(*lr.Password) < PASSWORD_MIN_LENGTH {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "invalid name or password too short")
+			return
+		}
+		if _, err := ws.users.Register(*lr.Name, *lr.Password); err != nil {
+			ws.userError(res, err)
+			return
+		}
+		s, err := ws.login(res, req, *lr.Name)
+		if err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		ws.writeSession(res, s, http.StatusCreated)
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
+
+// Session shows the logged in user and their wallets on GET, logs in on
+// POST and out on DELETE.
+func (ws *WalletServer) Session(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		if s := ws.session(res, req); s != nil {
+			ws.writeSession(res, s, http.StatusOK)
+		}
+	case http.MethodPost:
+		decoder := json.NewDecoder(req.Body)
+		var lr common.LoginRequest
+		if err := decoder.Decode(&lr); err != nil || !lr.Validate() {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing name or password")
+			return
+		}
+		if err := ws.users.Authenticate(*lr.Name, *lr.Password); err != nil {
+			log.Printf("ERROR: login of %q: %v", *lr.Name, err)
+			ws.userError(res, err)
+			return
+		}
+		s, err := ws.login(res, req, *lr.Name)
+		if err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		ws.writeSession(res, s, http.StatusOK)
+	case http.MethodDelete:
+		if s := ws.session(res, req); s != nil {
+			ws.logout(res, s)
+			res.Header().Add("Content-Type", "application/json")
+			io.WriteString(res, string(common.JsonStatus("logged out")))
+		}
+	default:
+		common.MethodNotAllowed(res, req, http.MethodGet, http.MethodPost, http.MethodDelete)
+	}
+}
+
+// Wallets lists the user's wallets on GET and creates one, encrypted with
+// its own passphrase, on POST.
+func (ws *WalletServer) Wallets(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		if s := ws.session(res, req); s != nil {
+			ws.writeSession(res, s, http.StatusOK)
+		}
+	case http.MethodPost:
+		s := ws.session(res, req)
+		if s == nil {
+			return
+		}
+		decoder := json.NewDecoder(req.Body)
+		var wr common.WalletCreateRequest
+		if err := decoder.Decode(&wr); err != nil || !wr.Validate() {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing passphrase")
+			return
+		}
+		name := ""
+		if wr.Name != nil {
+			name = *wr.Name
+		}
+		scheme := common.P256
+		if wr.Scheme != nil {
+			var err error
+			if scheme, err = common.SchemeByName(*wr.Scheme); err != nil {
+				ws.keystoreError(res, err)
+				return
+			}
+		}
+		w, err := s.keystore.Create(scheme, *wr.Passphrase)
+		if err != nil {
+			ws.keystoreError(res, err)
+			return
+		}
+		if _, err := ws.addWallet(s, name, w.BlockchainAddress(), *wr.Passphrase); err != nil {
+			ws.userError(res, err)
+			return
+		}
+		ws.fund(w.BlockchainAddress())
+		ws.writeSession(res, s, http.StatusCreated)
+	default:
+		common.MethodNotAllowed(res, req, http.MethodGet, http.MethodPost)
+	}
+}
+
+// WalletSelect switches the session, and the user's next sessions, to
+// another of their wallets.
+func (ws *WalletServer) WalletSelect(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		s := ws.session(res, req)
+		if s == nil {
+			return
+		}
+		decoder := json.NewDecoder(req.Body)
+		var wr common.WalletSelectRequest
+		if err := decoder.Decode(&wr); err != nil || !wr.Validate() {
+			common.WriteError(res, http.StatusBadRequest, common.ERR_INVALID_REQUEST, "missing wallet name")
+			return
+		}
+		w, err := ws.users.Wallet(s.user, *wr.Name)
+		if err != nil {
+			ws.userError(res, err)
+			return
+		}
+		if err := ws.users.Select(s.user, w.Name); err != nil {
+			ws.userError(res, err)
+			return
+		}
+		s.selectWallet(w)
+		ws.writeSession(res, s, http.StatusOK)
+	default:
+		common.MethodNotAllowed(res, req, http.MethodPost)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+	"log"
+	"os"
+	"regexp"
+	"sync"
+)
+
+const (
+	// scrypt parameters of login passwords, lighter than the keystore's
+	// as they are checked on every login
+	PASSWORD_SCRYPT_N     = 1 << 15
+	PASSWORD_SCRYPT_R     = 8
+	PASSWORD_SCRYPT_P     = 1
+	PASSWORD_SCRYPT_DKLEN = 32
+	PASSWORD_MIN_LENGTH   = 8
+)
+
+var (
+	ErrUserExists     = errors.New("user already exists")
+	ErrBadCredentials = errors.New("wrong user name or password")
+	ErrWalletExists   = errors.New("wallet name already used")
+	ErrNoWallet       = errors.New("no such wallet")
+
+	userNamePattern = regexp.MustCompile(`^[a-z0-9_-]{3,32}$`)
+)
+
+// NamedWallet is a key file of a user's keystore, by the name the user
+// gave it.
+type NamedWallet struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// User is an account of the wallet server. Its wallets are kept in a
+// keystore of its own.
+type User struct {
+	Name         string        `json:"name"`
+	Salt         string        `json:"salt"`
+	PasswordHash string        `json:"password_hash"`
+	Wallets      []NamedWallet `json:"wallets"`
+	Selected     string        `json:"selected,omitempty"`
+}
+
+type userStore struct {
+	path  string
+	users map[string]*User
+	mux   sync.Mutex
+}
+
+func newUserStore(path string) *userStore {
+	return &userStore{path: path, users: make(map[string]*User)}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Panicf("ERROR: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func hashPassword(password string, salt string) string {
+	s, _ := hex.DecodeString(salt)
+	key, err := scrypt.Key([]byte(password), s, PASSWORD_SCRYPT_N, PASSWORD_SCRYPT_R, PASSWORD_SCRYPT_P, PASSWORD_SCRYPT_DKLEN)
+	if err != nil {
+		log.Panicf("ERROR: %v", err)
+	}
+	return hex.EncodeToString(key)
+}
+
+// ValidUserName reports whether name can name a user, and its keystore
+// directory.
+func ValidUserName(name string) bool {
+	return userNamePattern.MatchString(name)
+}
+
+func (us *userStore) load() error {
+	data, err := os.ReadFile(us.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var users []*User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return fmt.Errorf("%s: %v", us.path, err)
+	}
+
+	us.mux.Lock()
+	defer us.mux.Unlock()
+	for _, u := range users {
+		us.users[u.Name] = u
+	}
+	log.Printf("action=users, status=loaded, count=%d", len(users))
+	return nil
+}
+
+// save writes the users to path. The caller must hold us.mux.
+func (us *userStore) save() error {
+	users := make([]*User, 0, len(us.users))
+	for _, u := range us.users {
+		users = append(users, u)
+	}
+	data, _ := json.MarshalIndent(users, "", "  ")
+	tmp := us.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, us.path)
+}
+
+// Register adds a user with password.
+func (us *userStore) Register(name string, password string) (*User, error) {
+	salt := randomHex(16)
+	u := &User{Name: name, Salt: salt, PasswordHash: hashPassword(password, salt), Wallets: make([]NamedWallet, 0)}
+
+	us.mux.Lock()
+	defer us.mux.Unlock()
+	if _, ok := us.users[name]; ok {
+		return nil, ErrUserExists
+	}
+	us.users[name] = u
+	if err := us.save(); err != nil {
+		delete(us.users, name)
+		return nil, err
+	}
+	return u, nil
+}
+
+// Authenticate checks the password of the user name.
+func (us *userStore) Authenticate(name string, password string) error {
+	us.mux.Lock()
+	u, ok := us.users[name]
+	us.mux.Unlock()
+	if !ok {
+		// spend as long as for a known user
+		hashPassword(password, randomHex(16))
+		return ErrBadCredentials
+	}
+	hash := hashPassword(password, u.Salt)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(u.PasswordHash)) != 1 {
+		return ErrBadCredentials
+	}
+	return nil
+}
+
+// Wallets lists the wallets of the user name and the one last selected.
+func (us *userStore) Wallets(name string) ([]NamedWallet, string) {
+	us.mux.Lock()
+	defer us.mux.Unlock()
+	u, ok := us.users[name]
+	if !ok {
+		return nil, ""
+	}
+	return append([]NamedWallet{}, u.Wallets...), u.Selected
+}
+
+// Wallet finds the wallet of the user name called walletName.
+func (us *userStore) Wallet(name string, walletName string) (*NamedWallet, error) {
+	wallets, _ := us.Wallets(name)
+	for _, w := range wallets {
+		if w.Name == walletName {
+			return &w, nil
+		}
+	}
+	return nil, ErrNoWallet
+}
+
+// AddWallet records the key file of address as a wallet of the user name,
+// called walletName or "wallet <n>" if empty, and selects it. A key file
+// added again, as when a mnemonic is restored twice, keeps its name.
+func (us *userStore) AddWallet(name string, walletName string, address string) (*NamedWallet, error) {
+	us.mux.Lock()
+	defer us.mux.Unlock()
+	u, ok := us.users[name]
+	if !ok {
+		return nil, ErrNoWallet
+	}
+	for _, w := range u.Wallets {
+		if w.Address == address {
+			u.Selected = w.Name
+			return &w, us.save()
+		}
+	}
+	if walletName == "" {
+		walletName = fmt.Sprintf("wallet %d", len(u.Wallets)+1)
+	}
+	for _, w := range u.Wallets {
+		if w.Name == walletName {
+			return nil, ErrWalletExists
+		}
+	}
+	w := NamedWallet{Name: walletName, Address: address}
+	u.Wallets = append(u.Wallets, w)
+	u.Selected = walletName
+	return &w, us.save()
+}
+
+// Select remembers walletName as the wallet the user name works with, in
+// this session and the next ones.
+func (us *userStore) Select(name string, walletName string) error {
+	us.mux.Lock()
+	defer us.mux.Unlock()
+	u, ok := us.users[name]
+	if !ok {
+		return ErrNoWallet
+	}
+	for _, w := range u.Wallets {
+		if w.Name == walletName {
+			u.Selected = walletName
+			return us.save()
+		}
+	}
+	return ErrNoWallet
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"goblockchain/common"
+	"goblockchain/wallet"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Signs transactions offline with the keys of a keystore of its own. The
+// wallet server watches their addresses and builds the transactions,
+// which are carried here as files or strings and back, signed, to be
+// broadcast.
+//
+//	wallet_signer [flags] new          create a key of -scheme, print its address
+//	wallet_signer [flags] import       store the private key read on stdin
+//	wallet_signer [flags] list         print the addresses of the keystore
+//	wallet_signer [flags] sign [file]  sign the transaction of file or stdin
+
+func init() {
+	log.SetPrefix(("GO-SIGNER: "))
+	log.SetFlags(0)
+}
+
+func main() {
+	networkName := flag.String("network", common.MAINNET.Name, "Network profile: mainnet, testnet or regtest")
+	keystoreDir := flag.String("keystore", "", "Directory of the encrypted key files (default: keystore/signer/<network>)")
+	passphraseFile := flag.String("passphrase-file", "", "File holding the keystore passphrase (default: $WALLET_PASSPHRASE)")
+	out := flag.String("out", "", "File the signed transaction is written to (default: stdout)")
+	encode := flag.Bool("encode", false, "Write the signed transaction as a base64 string instead of JSON")
+	schemeName := flag.String("scheme", "", "Signature scheme of new or hex imported keys: p256, secp256k1 or ed25519 (default: p256)")
+	flag.Parse()
+	network, err := common.NetworkByName(*networkName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var scheme common.SignatureScheme
+	if *schemeName != "" {
+		if scheme, err = common.SchemeByName(*schemeName); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *keystoreDir == "" {
+		*keystoreDir = filepath.Join("keystore", "signer", network.Name)
+	}
+	ks, err := wallet.NewKeystore(*keystoreDir, network)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch flag.Arg(0) {
+	case "new":
+		if scheme == nil {
+			scheme = common.P256
+		}
+		w, err := ks.Create(scheme, passphrase(*passphraseFile))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("address     %s\nscheme      %s\npublic key  %s\n", w.BlockchainAddress(), scheme.Name(), w.PublicKeyString())
+	case "import":
+		key, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		w, err := wallet.ParsePrivateKey(string(key), scheme, network)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := ks.Store(w, passphrase(*passphraseFile)); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("address     %s\n", w.BlockchainAddress())
+	case "list":
+		entries, err := ks.Addresses()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, entry := range entries {
+			accounts, err := ks.Accounts(entry)
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, a := range accounts {
+				fmt.Println(a.Address)
+			}
+		}
+	case "sign":
+		sign(ks, network, flag.Arg(1), *passphraseFile, *out, *encode)
+	default:
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] new | import | list | sign [file]\n", os.Args[0])
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+}
+
+// passphrase reads the keystore passphrase from file, or the environment
+// if file is empty, so it never shows in the shell history.
+func passphrase(file string) string {
+	if file == "" {
+		p := os.Getenv("WALLET_PASSPHRASE")
+		if p == "" {
+			log.Fatal("no passphrase: set WALLET_PASSPHRASE or use -passphrase-file")
+		}
+		return p
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return strings.TrimRight(string(data), "\r\n")
+}
+
+// sign signs the unsigned transaction of file, or stdin if empty or "-",
+// with the key of its sender and writes it to out, or stdout if empty.
+func sign(ks *wallet.Keystore, network *common.Network, file string, passphraseFile string, out string, encode bool) {
+	var data []byte
+	var err error
+	if file == "" || file == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	u, err := common.DecodeUnsignedTransaction(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := u.Check(network); err != nil {
+		log.Fatal(err)
+	}
+	// shown before signing, as the only account of what is being signed
+	fmt.Fprintf(os.Stderr, "sender      %s\nrecipient   %s\nvalue       %g\nfee         %g\nnonce       %d\nbalance     %g at height %d\n",
+		u.SenderAddress, u.RecipientAddress, u.Value, u.Fee, u.Nonce, u.Balance, u.Height)
+	if float64(u.Value+u.Fee) > u.Balance {
+		log.Printf("WARNING: value and fee exceed the balance when the transaction was built")
+	}
+
+	entry, err := ks.Entry(u.SenderAddress)
+	if err != nil {
+		log.Fatalf("%s: %v", u.SenderAddress, err)
+	}
+	w, err := ks.Decrypt(entry, u.SenderAddress, passphrase(passphraseFile))
+	if err != nil {
+		log.Fatal(err)
+	}
+	t, err := w.SignUnsignedTransaction(u, network)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var signed string
+	if encode {
+		signed = common.EncodeSignedTransactionString(t) + "\n"
+	} else {
+		m, _ := json.MarshalIndent(t, "", "  ")
+		signed = string(m) + "\n"
+	}
+	var dst io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		dst = f
+	}
+	if _, err := io.WriteString(dst, signed); err != nil {
+		log.Fatal(err)
+	}
+}